@@ -12,6 +12,165 @@ import (
 	"github.com/oapi-codegen/runtime"
 )
 
+// AuthInfo Outcome of the authentication check of a request to a protected path
+type AuthInfo struct {
+	// Authenticated Whether the request carries valid credentials
+	Authenticated bool `json:"authenticated"`
+
+	// Challenges WWW-Authenticate challenges sent in the response
+	Challenges *[]string `json:"challenges,omitempty"`
+
+	// Prefix Protected path prefix matching the request path
+	Prefix string `json:"prefix"`
+
+	// Realm Protection space advertised in the challenges
+	Realm string `json:"realm"`
+
+	// Reason Explanation of the authentication check result
+	Reason string `json:"reason"`
+
+	// Scheme Authentication scheme used in the request Authorization header
+	Scheme *string `json:"scheme,omitempty"`
+
+	// Schemes Authentication schemes accepted for the protected path
+	Schemes []string `json:"schemes"`
+
+	// User User name sent in the request credentials
+	User *string `json:"user,omitempty"`
+}
+
+// ChaosStats Counters of the faults injected by the chaos engine
+type ChaosStats struct {
+	// Injected Number of injected faults, by fault
+	Injected map[string]int64 `json:"injected"`
+
+	// Requests Number of requests evaluated by the chaos engine
+	Requests int64 `json:"requests"`
+}
+
+// ClientBrand Brand of the client with its version, from the User-Agent client hints
+type ClientBrand struct {
+	// Brand Name of the brand
+	Brand string `json:"brand"`
+
+	// Version Version of the brand
+	Version string `json:"version"`
+}
+
+// ClientHints User-Agent client hints of the request (Sec-CH-UA*)
+type ClientHints struct {
+	// Arch CPU architecture (Sec-CH-UA-Arch)
+	Arch *string `json:"arch,omitempty"`
+
+	// Bitness CPU bitness (Sec-CH-UA-Bitness)
+	Bitness *string `json:"bitness,omitempty"`
+
+	// Brands Brands and significant versions (Sec-CH-UA)
+	Brands *[]ClientBrand `json:"brands,omitempty"`
+
+	// Errors Malformed client hints
+	Errors *[]string `json:"errors,omitempty"`
+
+	// FormFactors Form factors of the device (Sec-CH-UA-Form-Factors)
+	FormFactors *[]string `json:"formFactors,omitempty"`
+
+	// FullVersion Full browser version (Sec-CH-UA-Full-Version, deprecated)
+	FullVersion *string `json:"fullVersion,omitempty"`
+
+	// FullVersionList Brands and full versions (Sec-CH-UA-Full-Version-List)
+	FullVersionList *[]ClientBrand `json:"fullVersionList,omitempty"`
+
+	// Mobile Whether the client prefers a mobile experience (Sec-CH-UA-Mobile)
+	Mobile *bool `json:"mobile,omitempty"`
+
+	// Model Device model (Sec-CH-UA-Model)
+	Model *string `json:"model,omitempty"`
+
+	// Platform Operating system (Sec-CH-UA-Platform)
+	Platform *string `json:"platform,omitempty"`
+
+	// PlatformVersion Operating system version (Sec-CH-UA-Platform-Version)
+	PlatformVersion *string `json:"platformVersion,omitempty"`
+
+	// Wow64 Whether a 32-bit binary runs on 64-bit Windows (Sec-CH-UA-WoW64)
+	Wow64 *bool `json:"wow64,omitempty"`
+}
+
+// ClientInfo Classification of the client from the User-Agent, and its client hints
+type ClientInfo struct {
+	// Bot Name of the crawler or bot
+	Bot *string `json:"bot,omitempty"`
+
+	// Browser Name of the browser, command line tool or HTTP library
+	Browser *string `json:"browser,omitempty"`
+
+	// BrowserVersion Version of the browser, command line tool or HTTP library
+	BrowserVersion *string `json:"browserVersion,omitempty"`
+
+	// Device Device type: desktop, mobile, tablet, tv, console, bot, library or unknown
+	Device string `json:"device"`
+
+	// Engine Rendering engine of the browser
+	Engine *string `json:"engine,omitempty"`
+
+	// Hints User-Agent client hints of the request (Sec-CH-UA*)
+	Hints *ClientHints `json:"hints,omitempty"`
+
+	// Os Operating system
+	Os *string `json:"os,omitempty"`
+
+	// OsVersion Operating system version
+	OsVersion *string `json:"osVersion,omitempty"`
+}
+
+// CorsInfo Evaluation of the request against the configured CORS policy
+type CorsInfo struct {
+	// Allowed Whether the request origin is allowed by the CORS policy
+	Allowed bool `json:"allowed"`
+
+	// MatchedRule CORS origin rule that matched the request origin
+	MatchedRule *string `json:"matchedRule,omitempty"`
+
+	// Origin Origin header received in the request
+	Origin *string `json:"origin,omitempty"`
+
+	// Preflight Whether the request is a CORS preflight request
+	Preflight bool `json:"preflight"`
+
+	// Reasons Explanation of the CORS policy decision
+	Reasons []string `json:"reasons"`
+
+	// RequestHeaders Headers listed in the Access-Control-Request-Headers preflight header
+	RequestHeaders *[]string `json:"requestHeaders,omitempty"`
+
+	// RequestMethod Method in the Access-Control-Request-Method preflight header
+	RequestMethod *string `json:"requestMethod,omitempty"`
+}
+
+// EdgeInfo Edge platform the request went through, detected from its header signatures
+type EdgeInfo struct {
+	// ClientIp Client address reported by the edge
+	ClientIp *string `json:"clientIp,omitempty"`
+
+	// ClientIpHeader Header holding the client address reported by the edge
+	ClientIpHeader *string `json:"clientIpHeader,omitempty"`
+
+	// Country Country code of the client reported by the edge
+	Country *string `json:"country,omitempty"`
+
+	// Pop Point of presence or region of the edge serving the request
+	Pop *string `json:"pop,omitempty"`
+
+	// Provider Edge provider: cloudflare, fastly, akamai, cloudfront, azure-front-door, google-cloud-lb, vercel or netlify
+	Provider string `json:"provider"`
+
+	// RequestId ID assigned to the request by the edge
+	RequestId *string `json:"requestId,omitempty"`
+
+	// Signatures Headers identifying the edge provider
+	Signatures []string `json:"signatures"`
+}
+
 // ErrorResponse Error response
 type ErrorResponse struct {
 	// Code Error code
@@ -21,35 +180,366 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-// HeaderResponse Response containing echoed HTTP headers and request information
+// Exchange Exchange recorded in the request history
+type Exchange struct {
+	// Fault Fault injected by the chaos engine
+	Fault *string `json:"fault,omitempty"`
+
+	// Format Format of the echoed response
+	Format string `json:"format"`
+
+	// Id ID of the exchange, as sent in the Headertrace-Request-Id response header
+	Id int64 `json:"id"`
+
+	// Received Time the request was received, in RFC 3339 format
+	Received string `json:"received"`
+
+	// Remote Client address, resolved through the trusted proxies
+	Remote string `json:"remote"`
+
+	// Response Response containing echoed HTTP headers and request information.
+	// In the XML representation, the entries of the header maps are "entry" elements
+	// with a "name" attribute, and the array items are "item" elements.
+	Response HeaderResponse `json:"response"`
+
+	// ResponseHeaders Headers of the echoed response
+	ResponseHeaders map[string]string `json:"responseHeaders"`
+
+	// Status Status code of the echoed response
+	Status int `json:"status"`
+}
+
+// ExchangeList Exchanges recorded in the request history
+type ExchangeList struct {
+	// Capacity Number of exchanges the history keeps before evicting the oldest ones
+	Capacity int `json:"capacity"`
+
+	// Exchanges Summaries of the recorded exchanges, from the most recent one
+	Exchanges []ExchangeSummary `json:"exchanges"`
+
+	// Recorded Number of exchanges recorded since the server started, evicted or cleared ones included
+	Recorded int64 `json:"recorded"`
+}
+
+// ExchangeSummary Summary of an exchange recorded in the request history
+type ExchangeSummary struct {
+	// Id ID of the exchange
+	Id int64 `json:"id"`
+
+	// Method HTTP method of the request
+	Method string `json:"method"`
+
+	// Path Path of the request
+	Path string `json:"path"`
+
+	// Received Time the request was received, in RFC 3339 format
+	Received string `json:"received"`
+
+	// Remote Client address, resolved through the trusted proxies
+	Remote string `json:"remote"`
+
+	// Status Status code of the echoed response
+	Status int `json:"status"`
+
+	// UserAgent User-Agent of the request
+	UserAgent *string `json:"userAgent,omitempty"`
+}
+
+// GeoInfo Geolocation and autonomous system of the client address, from the GeoIP databases
+type GeoInfo struct {
+	// Address Client address looked up, resolved through the trusted proxies
+	Address string `json:"address"`
+
+	// Asn Number of the autonomous system announcing the address
+	Asn *int `json:"asn,omitempty"`
+
+	// City English name of the city
+	City *string `json:"city,omitempty"`
+
+	// Country ISO 3166-1 alpha-2 code of the country
+	Country *string `json:"country,omitempty"`
+
+	// CountryName English name of the country
+	CountryName *string `json:"countryName,omitempty"`
+
+	// Organization Organization of the autonomous system, or of the address for the ISP databases
+	Organization *string `json:"organization,omitempty"`
+}
+
+// HeaderResponse Response containing echoed HTTP headers and request information.
+// In the XML representation, the entries of the header maps are "entry" elements
+// with a "name" attribute, and the array items are "item" elements.
 type HeaderResponse struct {
+	// Auth Outcome of the authentication check of a request to a protected path
+	Auth *AuthInfo `json:"auth,omitempty"`
+
+	// Client Classification of the client from the User-Agent, and its client hints
+	Client *ClientInfo `json:"client,omitempty"`
+
+	// Cors Evaluation of the request against the configured CORS policy
+	Cors *CorsInfo `json:"cors,omitempty"`
+
+	// Edge Edge platforms (CDNs, load balancers) the request went through, detected from their header signatures
+	Edge *[]EdgeInfo `json:"edge,omitempty"`
+
+	// Geo Geolocation and autonomous system of the client address, from the GeoIP databases
+	Geo *GeoInfo `json:"geo,omitempty"`
+
 	// Headers HTTP headers received in the request
 	Headers map[string]string `json:"headers"`
 
 	// Host Host and port of the server
 	Host string `json:"host"`
 
+	// Jwt JSON Web Tokens of the Authorization Bearer header and of the configured cookies
+	Jwt *[]JWTInfo `json:"jwt,omitempty"`
+
+	// Lint Protocol problems of the received header fields (RFC 9110, RFC 9112)
+	Lint *[]LintIssue `json:"lint,omitempty"`
+
 	// Method HTTP method of the request
 	Method string `json:"method"`
 
+	// Negotiation Accept, Accept-Language, Accept-Encoding and Accept-Charset headers of the request, with the variants they select
+	Negotiation *NegotiationInfo `json:"negotiation,omitempty"`
+
 	// Path Request path
 	Path string `json:"path"`
 
 	// Protocol HTTP protocol version
 	Protocol string `json:"protocol"`
 
+	// ProxyChain Proxy hops parsed from the Forwarded, X-Forwarded-* and Via headers, from the closest to the client
+	ProxyChain *[]ProxyHop `json:"proxyChain,omitempty"`
+
+	// ProxyInconsistencies Inconsistencies between the Forwarded and X-Forwarded-* headers
+	ProxyInconsistencies *[]string `json:"proxyInconsistencies,omitempty"`
+
+	// Remote Client address of the request, resolved through the trusted proxies
+	Remote *RemoteInfo `json:"remote,omitempty"`
+
 	// Sent HTTP headers sent in the HTTP response
 	Sent *map[string]string `json:"sent,omitempty"`
+
+	// Smuggling Request smuggling (desync) indicators of the message framing on the connection
+	Smuggling *[]LintIssue `json:"smuggling,omitempty"`
+
+	// Trace Distributed trace contexts decoded from the tracing headers
+	Trace *TraceInfo `json:"trace,omitempty"`
+}
+
+// JWTInfo JSON Web Token decoded from the request
+type JWTInfo struct {
+	// Audience Audience of the token (aud claim)
+	Audience *[]string `json:"audience,omitempty"`
+
+	// Claims Claims of the token
+	Claims *map[string]interface{} `json:"claims,omitempty"`
+
+	// Error Why the token cannot be decoded, or its signature verified
+	Error *string `json:"error,omitempty"`
+
+	// ExpiresAt Expiration time of the token (exp claim), in RFC 3339 format
+	ExpiresAt *string `json:"expiresAt,omitempty"`
+
+	// Expiry Expiry status of the token from the exp and nbf claims: active, expired, not-yet-valid or no-expiry
+	Expiry *string `json:"expiry,omitempty"`
+
+	// Header JOSE header of the token
+	Header *map[string]interface{} `json:"header,omitempty"`
+
+	// Issuer Issuer of the token (iss claim)
+	Issuer *string `json:"issuer,omitempty"`
+
+	// Signature Outcome of the signature verification: valid, invalid, or unverified when no key is configured for the algorithm
+	Signature string `json:"signature"`
+
+	// Source Where the token was found: authorization, or cookie:<name>
+	Source string `json:"source"`
+
+	// Subject Subject of the token (sub claim)
+	Subject *string `json:"subject,omitempty"`
+}
+
+// LintIssue Protocol problem of the received request
+type LintIssue struct {
+	// Field Name of the header field with the problem
+	Field *string `json:"field,omitempty"`
+
+	// Message Description of the problem
+	Message string `json:"message"`
+
+	// Rule Rule flagging the problem: non-ascii, obs-fold, repeated-singleton, hop-by-hop or oversized-value for the lint section, cl-te, multiple-content-length, obfuscated-te, chunk-size or pipelined-request for the smuggling section
+	Rule string `json:"rule"`
+
+	// Severity Severity of the problem: error for violations of the specifications, warning for discouraged constructs
+	Severity string `json:"severity"`
+}
+
+// NegotiationEntry Element of an Accept-* header with its weight
+type NegotiationEntry struct {
+	// Q Weight of the element (q-value), 1 by default
+	Q float32 `json:"q"`
+
+	// Value Media range, language range, content coding or charset, along with the media range parameters
+	Value string `json:"value"`
+}
+
+// NegotiationInfo Accept, Accept-Language, Accept-Encoding and Accept-Charset headers of the request, with the variants they select
+type NegotiationInfo struct {
+	// Accept Elements of an Accept-* header, and the variant they select among the values supported by the server
+	Accept *NegotiationResult `json:"accept,omitempty"`
+
+	// AcceptCharset Elements of an Accept-* header, and the variant they select among the values supported by the server
+	AcceptCharset *NegotiationResult `json:"acceptCharset,omitempty"`
+
+	// AcceptEncoding Elements of an Accept-* header, and the variant they select among the values supported by the server
+	AcceptEncoding *NegotiationResult `json:"acceptEncoding,omitempty"`
+
+	// AcceptLanguage Elements of an Accept-* header, and the variant they select among the values supported by the server
+	AcceptLanguage *NegotiationResult `json:"acceptLanguage,omitempty"`
+}
+
+// NegotiationResult Elements of an Accept-* header, and the variant they select among the values supported by the server
+type NegotiationResult struct {
+	// Entries Valid elements of the header, sorted by decreasing q-value
+	Entries []NegotiationEntry `json:"entries"`
+
+	// Errors Malformed elements of the header, left out of the entries
+	Errors *[]string `json:"errors,omitempty"`
+
+	// Selected Supported value with the highest q-value, omitted when none is acceptable or no values are configured
+	Selected *string `json:"selected,omitempty"`
+}
+
+// ProxyHop Proxy hop of the request, merging the entries of the Forwarded, X-Forwarded-* and Via headers
+type ProxyHop struct {
+	// By Interface of the proxy receiving the request (Forwarded by parameter)
+	By *string `json:"by,omitempty"`
+
+	// For Node the proxy received the request from (Forwarded for parameter or X-Forwarded-For)
+	For *string `json:"for,omitempty"`
+
+	// Host Host requested to the proxy (Forwarded host parameter or X-Forwarded-Host)
+	Host *string `json:"host,omitempty"`
+
+	// Port Port requested to the proxy (X-Forwarded-Port)
+	Port *string `json:"port,omitempty"`
+
+	// Prefix Path prefix stripped by the proxy (X-Forwarded-Prefix)
+	Prefix *string `json:"prefix,omitempty"`
+
+	// Proto Protocol requested to the proxy (Forwarded proto parameter or X-Forwarded-Proto)
+	Proto *string `json:"proto,omitempty"`
+
+	// Via Protocol and name of the proxy (Via)
+	Via *string `json:"via,omitempty"`
+}
+
+// RemoteInfo Client address of the request, resolved through the trusted proxies
+type RemoteInfo struct {
+	// Address Resolved IP address of the client
+	Address string `json:"address"`
+
+	// Method How the client address was resolved: remote-addr, x-forwarded-for, forwarded, x-real-ip or cf-connecting-ip
+	Method string `json:"method"`
+
+	// Peer IP address of the TCP peer of the connection
+	Peer string `json:"peer"`
+
+	// PeerTrusted Whether the TCP peer is a trusted proxy
+	PeerTrusted bool `json:"peerTrusted"`
+}
+
+// TraceContext Trace context decoded from a tracing header format
+type TraceContext struct {
+	// Debug Whether the debug flag is set
+	Debug *bool `json:"debug,omitempty"`
+
+	// Error Why the context is invalid, or why its tracestate was discarded
+	Error *string `json:"error,omitempty"`
+
+	// Format Header format of the context: traceparent, b3, x-b3, uber-trace-id, x-cloud-trace-context or x-amzn-trace-id
+	Format string `json:"format"`
+
+	// ParentSpanId Span ID of the parent of the caller span, as 16 lower case hex digits
+	ParentSpanId *string `json:"parentSpanId,omitempty"`
+
+	// Sampled Sampling decision
+	Sampled *bool `json:"sampled,omitempty"`
+
+	// SpanId Span ID of the caller, as 16 lower case hex digits
+	SpanId *string `json:"spanId,omitempty"`
+
+	// State List members of the W3C tracestate header
+	State *[]string `json:"state,omitempty"`
+
+	// TraceId Trace ID, as 32 lower case hex digits
+	TraceId *string `json:"traceId,omitempty"`
+
+	// Valid Whether the header is valid
+	Valid bool `json:"valid"`
+}
+
+// TraceInfo Distributed trace contexts decoded from the tracing headers
+type TraceInfo struct {
+	// Contexts Trace contexts, one for each header format found in the request
+	Contexts []TraceContext `json:"contexts"`
+
+	// Mismatches Trace IDs, span IDs or sampling decisions differing between the valid contexts
+	Mismatches *[]string `json:"mismatches,omitempty"`
 }
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 
+	// (DELETE /)
+	Delete(w http.ResponseWriter, r *http.Request)
+
 	// (GET /)
 	Get(w http.ResponseWriter, r *http.Request)
 
+	// (OPTIONS /)
+	Options(w http.ResponseWriter, r *http.Request)
+
+	// (PATCH /)
+	Patch(w http.ResponseWriter, r *http.Request)
+
+	// (POST /)
+	Post(w http.ResponseWriter, r *http.Request)
+
+	// (PUT /)
+	Put(w http.ResponseWriter, r *http.Request)
+
+	// (GET /_headertrace/chaos)
+	GetChaosStats(w http.ResponseWriter, r *http.Request)
+
+	// (DELETE /_headertrace/requests)
+	ClearRequests(w http.ResponseWriter, r *http.Request)
+
+	// (GET /_headertrace/requests)
+	ListRequests(w http.ResponseWriter, r *http.Request)
+
+	// (GET /_headertrace/requests/{id})
+	GetRequest(w http.ResponseWriter, r *http.Request, id int64)
+
+	// (DELETE /{matchall})
+	DeleteMatchall(w http.ResponseWriter, r *http.Request, matchall string)
+
 	// (GET /{matchall})
 	GetMatchall(w http.ResponseWriter, r *http.Request, matchall string)
+
+	// (OPTIONS /{matchall})
+	OptionsMatchall(w http.ResponseWriter, r *http.Request, matchall string)
+
+	// (PATCH /{matchall})
+	PatchMatchall(w http.ResponseWriter, r *http.Request, matchall string)
+
+	// (POST /{matchall})
+	PostMatchall(w http.ResponseWriter, r *http.Request, matchall string)
+
+	// (PUT /{matchall})
+	PutMatchall(w http.ResponseWriter, r *http.Request, matchall string)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -61,6 +551,20 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(http.Handler) http.Handler
 
+// Delete operation middleware
+func (siw *ServerInterfaceWrapper) Delete(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Delete(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // Get operation middleware
 func (siw *ServerInterfaceWrapper) Get(w http.ResponseWriter, r *http.Request) {
 
@@ -75,6 +579,154 @@ func (siw *ServerInterfaceWrapper) Get(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
+// Options operation middleware
+func (siw *ServerInterfaceWrapper) Options(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Options(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Patch operation middleware
+func (siw *ServerInterfaceWrapper) Patch(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Patch(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Post operation middleware
+func (siw *ServerInterfaceWrapper) Post(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Post(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// Put operation middleware
+func (siw *ServerInterfaceWrapper) Put(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.Put(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetChaosStats operation middleware
+func (siw *ServerInterfaceWrapper) GetChaosStats(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetChaosStats(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ClearRequests operation middleware
+func (siw *ServerInterfaceWrapper) ClearRequests(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ClearRequests(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListRequests operation middleware
+func (siw *ServerInterfaceWrapper) ListRequests(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListRequests(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetRequest operation middleware
+func (siw *ServerInterfaceWrapper) GetRequest(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id int64
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", r.PathValue("id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetRequest(w, r, id)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteMatchall operation middleware
+func (siw *ServerInterfaceWrapper) DeleteMatchall(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "matchall" -------------
+	var matchall string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "matchall", r.PathValue("matchall"), &matchall, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "matchall", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteMatchall(w, r, matchall)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // GetMatchall operation middleware
 func (siw *ServerInterfaceWrapper) GetMatchall(w http.ResponseWriter, r *http.Request) {
 
@@ -100,6 +752,106 @@ func (siw *ServerInterfaceWrapper) GetMatchall(w http.ResponseWriter, r *http.Re
 	handler.ServeHTTP(w, r)
 }
 
+// OptionsMatchall operation middleware
+func (siw *ServerInterfaceWrapper) OptionsMatchall(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "matchall" -------------
+	var matchall string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "matchall", r.PathValue("matchall"), &matchall, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "matchall", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.OptionsMatchall(w, r, matchall)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PatchMatchall operation middleware
+func (siw *ServerInterfaceWrapper) PatchMatchall(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "matchall" -------------
+	var matchall string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "matchall", r.PathValue("matchall"), &matchall, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "matchall", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PatchMatchall(w, r, matchall)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostMatchall operation middleware
+func (siw *ServerInterfaceWrapper) PostMatchall(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "matchall" -------------
+	var matchall string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "matchall", r.PathValue("matchall"), &matchall, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "matchall", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostMatchall(w, r, matchall)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PutMatchall operation middleware
+func (siw *ServerInterfaceWrapper) PutMatchall(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "matchall" -------------
+	var matchall string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "matchall", r.PathValue("matchall"), &matchall, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "matchall", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PutMatchall(w, r, matchall)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 type UnescapedCookieParamError struct {
 	ParamName string
 	Err       error
@@ -220,8 +972,22 @@ func HandlerWithOptions(si ServerInterface, options StdHTTPServerOptions) http.H
 		ErrorHandlerFunc:   options.ErrorHandlerFunc,
 	}
 
+	m.HandleFunc("DELETE "+options.BaseURL+"/{$}", wrapper.Delete)
 	m.HandleFunc("GET "+options.BaseURL+"/{$}", wrapper.Get)
+	m.HandleFunc("OPTIONS "+options.BaseURL+"/{$}", wrapper.Options)
+	m.HandleFunc("PATCH "+options.BaseURL+"/{$}", wrapper.Patch)
+	m.HandleFunc("POST "+options.BaseURL+"/{$}", wrapper.Post)
+	m.HandleFunc("PUT "+options.BaseURL+"/{$}", wrapper.Put)
+	m.HandleFunc("GET "+options.BaseURL+"/_headertrace/chaos", wrapper.GetChaosStats)
+	m.HandleFunc("DELETE "+options.BaseURL+"/_headertrace/requests", wrapper.ClearRequests)
+	m.HandleFunc("GET "+options.BaseURL+"/_headertrace/requests", wrapper.ListRequests)
+	m.HandleFunc("GET "+options.BaseURL+"/_headertrace/requests/{id}", wrapper.GetRequest)
+	m.HandleFunc("DELETE "+options.BaseURL+"/{matchall...}", wrapper.DeleteMatchall)
 	m.HandleFunc("GET "+options.BaseURL+"/{matchall...}", wrapper.GetMatchall)
+	m.HandleFunc("OPTIONS "+options.BaseURL+"/{matchall...}", wrapper.OptionsMatchall)
+	m.HandleFunc("PATCH "+options.BaseURL+"/{matchall...}", wrapper.PatchMatchall)
+	m.HandleFunc("POST "+options.BaseURL+"/{matchall...}", wrapper.PostMatchall)
+	m.HandleFunc("PUT "+options.BaseURL+"/{matchall...}", wrapper.PutMatchall)
 
 	return m
 }
@@ -1,15 +1,28 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/auth"
+	"github.com/fgiudici/headertrace/pkg/cache"
+	"github.com/fgiudici/headertrace/pkg/capture"
+	"github.com/fgiudici/headertrace/pkg/chaos"
+	"github.com/fgiudici/headertrace/pkg/client"
+	"github.com/fgiudici/headertrace/pkg/cors"
+	"github.com/fgiudici/headertrace/pkg/geoip"
 	hdrs "github.com/fgiudici/headertrace/pkg/headers"
+	"github.com/fgiudici/headertrace/pkg/history"
+	"github.com/fgiudici/headertrace/pkg/jwt"
 	"github.com/fgiudici/headertrace/pkg/logging"
+	"github.com/fgiudici/headertrace/pkg/negotiate"
+	"github.com/fgiudici/headertrace/pkg/proxy"
+	"github.com/fgiudici/headertrace/pkg/render"
 	"github.com/spf13/pflag"
 )
 
@@ -19,9 +32,45 @@ var (
 	headers      []string
 	dropHeaders  []string
 	sentHeaders  bool
+	cacheable    bool
 	privMode     bool
 	printVersion bool
 	logLevel     string
+	compact      bool
+	headerOrder  string
+	termTable    bool
+	jsonp        bool
+	acceptCH     []string
+
+	corsOrigins     []string
+	corsMethods     []string
+	corsHeaders     []string
+	corsCredentials bool
+	corsMaxAge      int
+
+	authRules  []string
+	authUsers  []string
+	authTokens []string
+	authRealm  string
+
+	jwtCookies []string
+	jwtJWKS    string
+	jwtSecret  string
+
+	supportedTypes     []string
+	supportedLanguages []string
+	supportedEncodings []string
+	supportedCharsets  []string
+
+	chaosRules []string
+
+	templateRules []string
+
+	trustedProxies []string
+	geoipDBs       []string
+
+	historySize  int
+	historyCreds bool
 )
 
 func init() {
@@ -33,69 +82,42 @@ func init() {
 
 	pflag.StringVarP(&host, "address", "a", "0.0.0.0", "IP address (or domain) to bind to")
 	pflag.StringVarP(&port, "port", "p", "8080", "TCP port to bind to")
-	pflag.StringSliceVarP(&headers, "header", "H", []string{}, "Custom HTTP headers to add to responses (key1:value1,key2:value2)")
+	pflag.StringSliceVarP(&headers, "header", "H", []string{}, "Custom HTTP headers to add to responses, values can be Go templates (key1:value1,key2:{{.Hostname}})")
 	pflag.StringSliceVarP(&dropHeaders, "drop-header", "D", []string{}, "HTTP headers to redact from request headers echoed in the response body (key1,key2)")
-	pflag.BoolVarP(&privMode, "privacy", "P", false, "Drop X-Forwarded and Cloudflare headers from request headers echoed in the response body")
+	pflag.BoolVarP(&privMode, "privacy", "P", false, "Drop X-Forwarded, X-Real-IP and edge provider (CDN) headers from request headers echoed in the response body")
 	pflag.BoolVarP(&sentHeaders, "sent", "s", false, "Dump the HTTP headers added in the response in the response body")
+	pflag.BoolVarP(&cacheable, "cacheable", "c", false, "Act as a cacheable origin: add ETag and Last-Modified, answer conditional requests with 304 and range requests with 206")
+	pflag.BoolVar(&compact, "compact", false, "Write compact single-line JSON by default (override with the 'pretty' query parameter)")
+	pflag.StringVar(&headerOrder, "header-order", render.OrderName, "Default order of the echoed headers in JSON and YAML: name, received (override with the 'order' query parameter)")
+	pflag.BoolVar(&jsonp, "jsonp", false, "Enable the JSONP format with the 'callback' query parameter: any web page can then read the echoed headers, cookies included")
+	pflag.BoolVar(&termTable, "terminal-table", false, "Return aligned tables to curl, wget and HTTPie when they accept any media type")
+	pflag.StringSliceVar(&acceptCH, "accept-ch", []string{}, "User-Agent client hints to request with Accept-CH, 'all' for all of them (Sec-CH-UA-Model,Sec-CH-UA-Platform-Version)")
 	pflag.BoolVarP(&printVersion, "version", "v", false, "Print version and exit")
+	pflag.StringSliceVar(&corsOrigins, "cors-origin", []string{}, "Enable CORS for the given origins: exact match, '*' for any origin or '~regex' (origin1,~regex2)")
+	pflag.StringSliceVar(&corsMethods, "cors-methods", []string{"GET", "HEAD", "POST"}, "Methods allowed in CORS preflight responses (method1,method2)")
+	pflag.StringSliceVar(&corsHeaders, "cors-headers", []string{}, "Request headers allowed in CORS preflight responses, '*' for any header (key1,key2)")
+	pflag.BoolVar(&corsCredentials, "cors-credentials", false, "Allow credentials in CORS requests")
+	pflag.IntVar(&corsMaxAge, "cors-max-age", 0, "Seconds CORS preflight responses can be cached by clients (0 to omit)")
+	pflag.StringSliceVar(&authRules, "auth", []string{}, "Protected path prefixes and accepted schemes: basic, digest, digest-sha256, bearer (/prefix1:scheme1,/prefix2:scheme2)")
+	pflag.StringSliceVar(&authUsers, "auth-user", []string{}, "Credentials accepted by the basic and digest schemes (user1:password1,user2:password2)")
+	pflag.StringSliceVar(&authTokens, "auth-token", []string{}, "Tokens accepted by the bearer scheme (token1,token2)")
+	pflag.StringVar(&authRealm, "auth-realm", "headertrace", "Realm advertised in authentication challenges")
+	pflag.StringSliceVar(&jwtCookies, "jwt-cookie", []string{}, "Cookies holding JWTs to decode, besides the Authorization Bearer token (cookie1,cookie2)")
+	pflag.StringVar(&jwtJWKS, "jwt-jwks", "", "JWKS file verifying the signatures of the decoded JWTs")
+	pflag.StringVar(&jwtSecret, "jwt-secret", "", "Shared secret verifying the HMAC signatures of the decoded JWTs")
+	pflag.StringSliceVar(&supportedTypes, "supported-types", []string{}, "Media types supported by the server, selected with the Accept header (type1/subtype1,type2/subtype2)")
+	pflag.StringSliceVar(&supportedLanguages, "supported-languages", []string{}, "Language tags supported by the server, selected with the Accept-Language header (en,fr-CA)")
+	pflag.StringSliceVar(&supportedEncodings, "supported-encodings", []string{}, "Content codings supported by the server, selected with the Accept-Encoding header (gzip,br)")
+	pflag.StringSliceVar(&supportedCharsets, "supported-charsets", []string{}, "Charsets supported by the server, selected with the Accept-Charset header (utf-8,iso-8859-1)")
+	pflag.StringSliceVar(&chaosRules, "chaos", []string{}, "Inject faults with the given probability, globally or for a path prefix: error, reset, truncate, bad-length, hang ([/prefix:]fault1=probability1,...)")
+	pflag.StringSliceVar(&trustedProxies, "trusted-proxies", []string{}, "Proxies trusted to report the client address in X-Forwarded-For and similar headers: CIDRs, IP addresses or presets cloudflare, loopback, rfc1918 (cidr1,preset2)")
+	pflag.StringSliceVar(&geoipDBs, "geoip-db", []string{}, "MaxMind DB files locating the client addresses: City, Country, ASN or ISP databases of MaxMind or DB-IP (file1.mmdb,file2.mmdb)")
+	pflag.IntVar(&historySize, "history", 0, "Number of echoed exchanges kept in memory and listed under /_headertrace/requests (0 to disable)")
+	pflag.BoolVar(&historyCreds, "history-credentials", false, "Keep the Authorization, Proxy-Authorization and Cookie headers and the decoded JWTs in the request history")
+	pflag.StringSliceVar(&templateRules, "template", []string{}, "Go template files rendering the response body, globally or for a path prefix ([/prefix:]file1,...)")
 	pflag.StringVarP(&logLevel, "log-level", "l", "", "Logging level: TRACE, DEBUG, INFO, WARN, ERROR (overrides the LOG_LEVEL env variable)")
 }
 
-type server struct {
-	headers     map[string]string
-	dropHeaders []string
-	privMode    bool
-	sentHeaders bool
-}
-
-// Get implements api.ServerInterface
-func (s *server) Get(w http.ResponseWriter, r *http.Request) {
-	logging.Infof("Received request: %s", hdrs.GetRemoteHostInfo(r))
-
-	// Convert headers to map
-	headers := hdrs.ToMap(r.Header, s.dropHeaders, s.privMode)
-	var xHeadersPtr *map[string]string
-
-	protocol := r.Proto
-	if protocol == "" {
-		protocol = "HTTP/1.1"
-	}
-
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
-	for key, value := range s.headers {
-		w.Header().Set(key, value)
-	}
-	w.WriteHeader(http.StatusOK)
-
-	if s.sentHeaders {
-		logging.Tracef("Dumping sent headers to response body")
-		xHeaders := hdrs.ToMap(w.Header(), nil, false)
-		xHeadersPtr = &xHeaders
-	}
-
-	// Create the response
-	response := api.HeaderResponse{
-		Headers:  headers,
-		Host:     r.Host,
-		Method:   r.Method,
-		Path:     r.RequestURI,
-		Protocol: protocol,
-		Sent:     xHeadersPtr,
-	}
-
-	// Encode and send the response
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(response); err != nil {
-		logging.Errorf("Error encoding response: %v", err)
-	}
-}
-
-func (s *server) GetMatchall(w http.ResponseWriter, r *http.Request, matchall string) {
-	s.Get(w, r) // Reuse the same logic for all paths
-}
-
 // Execute starts the HTTP server
 func Execute() error {
 	pflag.Parse()
@@ -122,6 +144,10 @@ func Execute() error {
 	if len(customHeaders) > 0 {
 		logging.Debugf("Custom headers to add in responses: %v", customHeaders)
 	}
+	headerTemplates, err := hdrs.ParseTemplates(customHeaders)
+	if err != nil {
+		logging.Fatalf("Custom headers: %v", err)
+	}
 
 	if len(dropHeaders) > 0 {
 		logging.Debugf("Headers to drop from echoed request headers: %v", dropHeaders)
@@ -129,18 +155,143 @@ func Execute() error {
 
 	logging.Debugf("Privacy mode: %v", privMode)
 	logging.Debugf("Dump sent headers: %v", sentHeaders)
+	logging.Debugf("Cacheable responses: %v", cacheable)
+
+	if err := render.CheckOrder(headerOrder); err != nil {
+		logging.Fatalf("Header order: %v", err)
+	}
+	logging.Debugf("Compact JSON: %v, header order: %s", compact, headerOrder)
+	logging.Debugf("Tables for terminal clients: %v", termTable)
+	if jsonp {
+		logging.Warnf("JSONP enabled: any web page can read the echoed headers, bypassing the CORS policy")
+	}
+
+	acceptCHValue, err := client.CheckHints(acceptCH)
+	if err != nil {
+		logging.Fatalf("Accept-CH: %v", err)
+	}
+	logging.Debugf("Requested client hints: '%s'", acceptCHValue)
 
 	// Create server instance
-	srv := &server{headers: customHeaders,
+	srv := &server{headers: headerTemplates,
 		dropHeaders: dropHeaders,
 		privMode:    privMode,
-		sentHeaders: sentHeaders}
+		sentHeaders: sentHeaders,
+		acceptCH:    acceptCHValue,
+		jsonOpts:    render.JSONOptions{Compact: compact, Order: headerOrder},
+		harOpts:     render.HAROptions{Version: getVersion()},
+		formatOpts:  render.NegotiateOptions{TerminalTable: termTable, JSONP: jsonp}}
+
+	if cacheable {
+		srv.cache = cache.NewValidator(time.Now())
+	}
+
+	if len(corsOrigins) > 0 {
+		srv.cors, err = cors.New(cors.Config{
+			Origins:     corsOrigins,
+			Methods:     corsMethods,
+			Headers:     corsHeaders,
+			Credentials: corsCredentials,
+			MaxAge:      corsMaxAge,
+		})
+		if err != nil {
+			logging.Fatalf("CORS: %v", err)
+		}
+		logging.Debugf("CORS allowed origins: %v", corsOrigins)
+	}
+
+	if len(authRules) > 0 {
+		srv.auth, err = auth.New(auth.Config{
+			Rules:  authRules,
+			Users:  authUsers,
+			Tokens: authTokens,
+			Realm:  authRealm,
+		})
+		if err != nil {
+			logging.Fatalf("Auth: %v", err)
+		}
+		logging.Debugf("Protected paths: %v", authRules)
+	}
+
+	srv.tokens, err = jwt.New(jwt.Config{
+		Cookies: jwtCookies,
+		JWKS:    jwtJWKS,
+		Secret:  jwtSecret,
+	})
+	if err != nil {
+		logging.Fatalf("JWT: %v", err)
+	}
+	logging.Debugf("JWT cookies: %v, JWKS: '%s', shared secret: %v", jwtCookies, jwtJWKS, jwtSecret != "")
+
+	srv.negotiator, err = negotiate.New(negotiate.Config{
+		Types:     supportedTypes,
+		Languages: supportedLanguages,
+		Encodings: supportedEncodings,
+		Charsets:  supportedCharsets,
+	})
+	if err != nil {
+		logging.Fatalf("Content negotiation: %v", err)
+	}
+	logging.Debugf("Supported types: %v, languages: %v, encodings: %v, charsets: %v", supportedTypes, supportedLanguages, supportedEncodings, supportedCharsets)
+
+	if len(chaosRules) > 0 {
+		srv.chaos, err = chaos.New(chaosRules)
+		if err != nil {
+			logging.Fatalf("Chaos: %v", err)
+		}
+		logging.Warnf("Chaos mode enabled: %v", chaosRules)
+	}
+
+	if len(trustedProxies) > 0 {
+		srv.proxies, err = proxy.NewResolver(trustedProxies)
+		if err != nil {
+			logging.Fatalf("Trusted proxies: %v", err)
+		}
+		logging.Debugf("Trusted proxies: %v", trustedProxies)
+	}
+
+	if len(geoipDBs) > 0 {
+		srv.geo, err = geoip.Open(geoipDBs)
+		if err != nil {
+			logging.Fatalf("GeoIP: %v", err)
+		}
+		defer srv.geo.Close()
+		logging.Debugf("GeoIP databases: %v", geoipDBs)
+	}
+
+	if historySize != 0 {
+		srv.history, err = history.New(historySize)
+		if err != nil {
+			logging.Fatalf("History: %v", err)
+		}
+		logging.Warnf("Request history enabled: keeping the last %d exchanges", historySize)
+		if !srv.auth.Protects("/_headertrace/requests") {
+			logging.Warnf("Request history readable by any client: protect it with --auth /_headertrace:<scheme>")
+		}
+		if historyCreds {
+			logging.Warnf("Request history keeping the client credentials")
+		}
+		srv.keepCreds = historyCreds
+	}
+
+	if len(templateRules) > 0 {
+		srv.templates, err = render.NewTemplates(templateRules)
+		if err != nil {
+			logging.Fatalf("Templates: %v", err)
+		}
+		logging.Debugf("Response body templates: %v", templateRules)
+	}
 
 	// Create handler from the generated code
 	handler := api.Handler(srv)
 
-	// Start listening
+	// Start listening: record the received bytes to echo requests as they were on the wire
 	addr := fmt.Sprintf("%s:%s", host, port)
 	logging.Infof("Starting server on %s", addr)
-	return http.ListenAndServe(addr, handler)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{Handler: handler, ConnContext: capture.ConnContext}
+	return httpServer.Serve(capture.NewListener(listener, capture.DefaultLimit))
 }
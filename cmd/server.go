@@ -0,0 +1,554 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/auth"
+	"github.com/fgiudici/headertrace/pkg/cache"
+	"github.com/fgiudici/headertrace/pkg/capture"
+	"github.com/fgiudici/headertrace/pkg/chaos"
+	"github.com/fgiudici/headertrace/pkg/client"
+	"github.com/fgiudici/headertrace/pkg/cors"
+	"github.com/fgiudici/headertrace/pkg/edge"
+	"github.com/fgiudici/headertrace/pkg/geoip"
+	hdrs "github.com/fgiudici/headertrace/pkg/headers"
+	"github.com/fgiudici/headertrace/pkg/history"
+	"github.com/fgiudici/headertrace/pkg/jwt"
+	"github.com/fgiudici/headertrace/pkg/lint"
+	"github.com/fgiudici/headertrace/pkg/logging"
+	"github.com/fgiudici/headertrace/pkg/negotiate"
+	"github.com/fgiudici/headertrace/pkg/proxy"
+	"github.com/fgiudici/headertrace/pkg/render"
+	"github.com/fgiudici/headertrace/pkg/smuggling"
+	"github.com/fgiudici/headertrace/pkg/trace"
+)
+
+type server struct {
+	headers     map[string]*hdrs.Template
+	dropHeaders []string
+	privMode    bool
+	sentHeaders bool
+	acceptCH    string
+	jsonOpts    render.JSONOptions
+	harOpts     render.HAROptions
+	formatOpts  render.NegotiateOptions
+	cors        *cors.Policy
+	cache       *cache.Validator
+	auth        *auth.Authenticator
+	tokens      *jwt.Decoder
+	negotiator  *negotiate.Negotiator
+	chaos       *chaos.Engine
+	templates   *render.Templates
+	proxies     *proxy.Resolver
+	geo         *geoip.DB
+	history     *history.History
+	keepCreds   bool
+}
+
+// remoteHostInfo formats the client of the request for the logs, with the address resolved
+// through the trusted proxies and located in the GeoIP databases.
+func (s *server) remoteHostInfo(r *http.Request) string {
+	remote := s.proxies.Resolve(r)
+	return hdrs.GetRemoteHostInfo(r, remoteAddress(r, remote, s.geo.Lookup(remote.Address)))
+}
+
+// remoteAddress describes the client address, followed by its location when geo is not nil.
+// When resolved through a trusted proxy, the proxy headers are inspected too: the location
+// falls back to the country reported by the edge providers (e.g. Cf-Ipcountry), and the peer
+// address is followed by the edge providers and their points of presence. They are not
+// inspected otherwise, as clients can spoof them.
+func remoteAddress(r *http.Request, remote api.RemoteInfo, geo *api.GeoInfo) string {
+	if remote.Method == proxy.MethodRemoteAddr {
+		return r.RemoteAddr + location(geo)
+	}
+
+	edges := edge.Detect(r.Header)
+	client := remote.Address + location(geo)
+	if geo == nil {
+		for _, e := range edges {
+			if e.Country != nil {
+				client = fmt.Sprintf("%s(%s)", client, *e.Country)
+				break
+			}
+		}
+	}
+	addr := fmt.Sprintf("%s %s [%s]", client, remote.Method, r.RemoteAddr)
+	for _, e := range edges {
+		addr += " via " + e.Provider
+		if e.Pop != nil {
+			addr += "/" + *e.Pop
+		}
+	}
+	return addr
+}
+
+// location formats the country, city and autonomous system of the GeoIP lookup, e.g.
+// "(IT, Milan, AS3269 Telecom Italia)". Returns an empty string if geo is nil.
+func location(geo *api.GeoInfo) string {
+	if geo == nil {
+		return ""
+	}
+	var parts []string
+	if geo.Country != nil {
+		parts = append(parts, *geo.Country)
+	}
+	if geo.City != nil {
+		parts = append(parts, *geo.City)
+	}
+	switch {
+	case geo.Asn != nil && geo.Organization != nil:
+		parts = append(parts, fmt.Sprintf("AS%d %s", *geo.Asn, *geo.Organization))
+	case geo.Asn != nil:
+		parts = append(parts, fmt.Sprintf("AS%d", *geo.Asn))
+	case geo.Organization != nil:
+		parts = append(parts, *geo.Organization)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// isPrivateHeader checks if a header may reveal sensitive information of the internal network,
+// to be dropped in privacy mode: the edge provider, X-Forwarded and X-Real-IP headers.
+func isPrivateHeader(header string) bool {
+	return edge.IsHeader(header) || hdrs.IsXForwardedHeader(header)
+}
+
+// Get implements api.ServerInterface
+func (s *server) Get(w http.ResponseWriter, r *http.Request) {
+	logging.Infof("Received request: %s", s.remoteHostInfo(r))
+	s.echo(w, r)
+}
+
+func (s *server) GetMatchall(w http.ResponseWriter, r *http.Request, matchall string) {
+	s.Get(w, r) // Reuse the same logic for all paths
+}
+
+// Post implements api.ServerInterface: the headers of POST, PUT, PATCH and DELETE requests,
+// e.g. webhook deliveries, are echoed back as for GET requests.
+func (s *server) Post(w http.ResponseWriter, r *http.Request) {
+	s.Get(w, r)
+}
+
+func (s *server) PostMatchall(w http.ResponseWriter, r *http.Request, matchall string) {
+	s.Get(w, r)
+}
+
+func (s *server) Put(w http.ResponseWriter, r *http.Request) {
+	s.Get(w, r)
+}
+
+func (s *server) PutMatchall(w http.ResponseWriter, r *http.Request, matchall string) {
+	s.Get(w, r)
+}
+
+func (s *server) Patch(w http.ResponseWriter, r *http.Request) {
+	s.Get(w, r)
+}
+
+func (s *server) PatchMatchall(w http.ResponseWriter, r *http.Request, matchall string) {
+	s.Get(w, r)
+}
+
+func (s *server) Delete(w http.ResponseWriter, r *http.Request) {
+	s.Get(w, r)
+}
+
+func (s *server) DeleteMatchall(w http.ResponseWriter, r *http.Request, matchall string) {
+	s.Get(w, r)
+}
+
+// Options implements api.ServerInterface: CORS preflight requests are answered according to the
+// configured CORS policy, and the received headers are echoed back as for GET requests.
+func (s *server) Options(w http.ResponseWriter, r *http.Request) {
+	logging.Infof("Received request: %s", s.remoteHostInfo(r))
+	w.Header().Set("Allow", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+	s.echo(w, r)
+}
+
+func (s *server) OptionsMatchall(w http.ResponseWriter, r *http.Request, matchall string) {
+	s.Options(w, r)
+}
+
+// GetChaosStats implements api.ServerInterface: it returns the chaos engine counters,
+// or echoes back the request headers as for any other path when chaos mode is disabled.
+func (s *server) GetChaosStats(w http.ResponseWriter, r *http.Request) {
+	if s.chaos == nil {
+		s.Get(w, r)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+	logging.Debugf("Chaos stats requested: %s", s.remoteHostInfo(r))
+	writeJSON(w, http.StatusOK, s.chaos.Stats())
+}
+
+// ListRequests implements api.ServerInterface: it returns the summaries of the recorded exchanges,
+// or echoes back the request headers as for any other path when the request history is disabled.
+func (s *server) ListRequests(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		s.Get(w, r)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+	logging.Debugf("Request history requested: %s", s.remoteHostInfo(r))
+	writeJSON(w, http.StatusOK, s.history.List())
+}
+
+// GetRequest implements api.ServerInterface: it returns a recorded exchange, or echoes back
+// the request headers as for any other path when the request history is disabled.
+func (s *server) GetRequest(w http.ResponseWriter, r *http.Request, id int64) {
+	if s.history == nil {
+		s.Get(w, r)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+	logging.Debugf("Recorded request %d requested: %s", id, s.remoteHostInfo(r))
+	exchange, ok := s.history.Get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, api.ErrorResponse{
+			Code:    http.StatusNotFound,
+			Message: fmt.Sprintf("request %d not found in the history", id),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, exchange)
+}
+
+// ClearRequests implements api.ServerInterface: it removes all the recorded exchanges, or echoes
+// back the request headers as for any other path when the request history is disabled.
+func (s *server) ClearRequests(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		s.Delete(w, r)
+		return
+	}
+	if !s.authorized(w, r) {
+		return
+	}
+	logging.Infof("Request history cleared: %s", s.remoteHostInfo(r))
+	s.history.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorized checks the credentials of the requests to the reserved /_headertrace paths, as
+// for the echoed ones, replying 401 Unauthorized with the challenges when they are not valid.
+func (s *server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.auth == nil {
+		return true
+	}
+	if info := s.auth.Check(w, r); info != nil && !info.Authenticated {
+		logging.Infof("Unauthorized request: %s", s.remoteHostInfo(r))
+		writeJSON(w, http.StatusUnauthorized, api.ErrorResponse{
+			Code:    http.StatusUnauthorized,
+			Message: info.Reason,
+		})
+		return false
+	}
+	return true
+}
+
+// writeJSON writes an indented JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logging.Errorf("Error encoding response: %v", err)
+	}
+}
+
+// echo writes back the received request headers in the response body.
+func (s *server) echo(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+
+	// Claim the raw header bytes of the request first, so that they are not mistaken for
+	// the ones of a later request on the same connection.
+	var raw []byte
+	conn, captured := capture.FromRequest(r)
+	if captured {
+		raw = conn.Header(r)
+	}
+
+	// Convert headers to map
+	var private func(string) bool
+	if s.privMode {
+		private = isPrivateHeader
+	}
+	headers, redacted := hdrs.Filter(r.Header, s.dropHeaders, private)
+	var xHeadersPtr *map[string]string
+
+	// Parse the proxy chain out of the echoed headers only, not to leak the redacted ones
+	visible := r.Header.Clone()
+	for _, name := range redacted {
+		visible.Del(name)
+	}
+	// The client address is neither echoed nor located if resolved from a redacted header
+	var remoteInfo *api.RemoteInfo
+	var geoInfo *api.GeoInfo
+	remote := s.proxies.Resolve(r)
+	clientAddress := remote.Peer
+	if !slices.ContainsFunc(redacted, func(name string) bool { return strings.EqualFold(name, remote.Method) }) {
+		if s.proxies != nil {
+			remoteInfo = &remote
+		}
+		geoInfo = s.geo.Lookup(remote.Address)
+		clientAddress = remote.Address
+	}
+
+	var chainPtr *[]api.ProxyHop
+	var inconsistenciesPtr *[]string
+	chain, inconsistencies := proxy.Chain(visible)
+	if len(chain) > 0 {
+		chainPtr = &chain
+	}
+	if len(inconsistencies) > 0 {
+		logging.Debugf("Proxy chain inconsistencies: %v", inconsistencies)
+		inconsistenciesPtr = &inconsistencies
+	}
+
+	var edgePtr *[]api.EdgeInfo
+	if edges := edge.Detect(visible); len(edges) > 0 {
+		edgePtr = &edges
+	}
+	clientInfo := client.Parse(visible)
+	negotiationInfo := s.negotiator.Analyze(visible)
+	traceInfo := trace.Decode(visible)
+	if traceInfo != nil && traceInfo.Mismatches != nil {
+		logging.Debugf("Trace context mismatches: %v", *traceInfo.Mismatches)
+	}
+
+	protocol := r.Proto
+	if protocol == "" {
+		protocol = "HTTP/1.1"
+	}
+
+	var corsInfo *api.CorsInfo
+	if s.cors != nil {
+		corsInfo = s.cors.Apply(w, r)
+	}
+
+	// Preflight requests never carry credentials: don't challenge them
+	status := http.StatusOK
+	var authInfo *api.AuthInfo
+	if s.auth != nil && !cors.IsPreflight(r) {
+		authInfo = s.auth.Check(w, r)
+		if authInfo != nil && !authInfo.Authenticated {
+			status = http.StatusUnauthorized
+		}
+	}
+
+	// The claims are shown even when the headers carrying the tokens are redacted
+	var jwtPtr *[]api.JWTInfo
+	if tokens := s.tokens.Decode(r, received); len(tokens) > 0 {
+		jwtPtr = &tokens
+	}
+
+	fault := chaos.None
+	if s.chaos != nil {
+		fault = s.chaos.Pick(r)
+	}
+	if fault == chaos.Error {
+		status = chaos.ErrorStatus()
+	}
+
+	format := render.Negotiate(r, s.formatOpts)
+	if s.templates != nil {
+		if tmpl := s.templates.Match(r); tmpl != nil {
+			format = tmpl
+		}
+	}
+	logging.Tracef("Rendering response as %s", format.Name)
+
+	// Set response headers
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Add("Vary", "Accept")
+	if s.acceptCH != "" {
+		w.Header().Set("Accept-CH", s.acceptCH)
+	}
+	added := []string{}
+	data := hdrs.NewTemplateData(r, clientAddress)
+	for key, tmpl := range s.headers {
+		value, err := tmpl.Execute(data)
+		if err != nil {
+			logging.Warnf("Custom header '%s': %v", key, err)
+			continue
+		}
+		w.Header().Set(key, value)
+		added = append(added, http.CanonicalHeaderKey(key))
+	}
+	var historyID int64
+	if s.history != nil {
+		historyID = s.history.NextID()
+		w.Header().Set(history.IDHeader, fmt.Sprint(historyID))
+	}
+
+	cacheable := s.cache != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) && status == http.StatusOK
+	if cacheable {
+		s.cache.SetHeaders(w, r, format.Name)
+	}
+
+	if s.sentHeaders {
+		logging.Tracef("Dumping sent headers to response body")
+		xHeaders := hdrs.ToMap(w.Header(), nil, nil)
+		xHeadersPtr = &xHeaders
+	}
+
+	var lintPtr *[]api.LintIssue
+	if issues := lint.Check(r, raw); len(issues) > 0 {
+		logging.Debugf("Lint issues: %d", len(issues))
+		lintPtr = &issues
+	}
+
+	// Read the body for the snippets, so that it is also recorded along with any request
+	// pipelined after it
+	var reqBody []byte
+	var bodyErr error
+	if r.ContentLength != 0 {
+		reqBody, bodyErr = io.ReadAll(io.LimitReader(r.Body, smuggling.MaxBody+1))
+	}
+	bodyTruncated := len(reqBody) > smuggling.MaxBody
+	if bodyTruncated {
+		reqBody = reqBody[:smuggling.MaxBody]
+	}
+	var smugglingPtr *[]api.LintIssue
+	if raw != nil {
+		if indicators := smuggling.Inspect(raw, conn.Pending(), bodyErr); len(indicators) > 0 {
+			rules := []string{}
+			for _, indicator := range indicators {
+				if !slices.Contains(rules, indicator.Rule) {
+					rules = append(rules, indicator.Rule)
+				}
+			}
+			logging.Warnf("Request smuggling indicators (%s): %s", strings.Join(rules, ", "), s.remoteHostInfo(r))
+			smugglingPtr = &indicators
+		}
+	}
+
+	// Create the response
+	response := api.HeaderResponse{
+		Auth:                 authInfo,
+		Client:               clientInfo,
+		Cors:                 corsInfo,
+		Edge:                 edgePtr,
+		Geo:                  geoInfo,
+		Headers:              headers,
+		Host:                 r.Host,
+		Jwt:                  jwtPtr,
+		Lint:                 lintPtr,
+		Method:               r.Method,
+		Negotiation:          negotiationInfo,
+		Path:                 r.RequestURI,
+		Protocol:             protocol,
+		ProxyChain:           chainPtr,
+		ProxyInconsistencies: inconsistenciesPtr,
+		Remote:               remoteInfo,
+		Sent:                 xHeadersPtr,
+		Smuggling:            smugglingPtr,
+		Trace:                traceInfo,
+	}
+
+	// Encode and send the response
+	echo := &render.Echo{
+		Response:        response,
+		Redacted:        redacted,
+		Added:           added,
+		ResponseHeaders: w.Header().Clone(),
+		Request:         r,
+		Raw:             raw,
+		Body:            reqBody,
+		BodyTruncated:   bodyTruncated || bodyErr != nil,
+		Status:          status,
+		Received:        received,
+		JSON:            s.jsonOpts,
+		HAR:             s.harOpts,
+	}
+	var body bytes.Buffer
+	if err := format.Render(&body, echo); err != nil {
+		logging.Errorf("Error encoding response: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	record := func(status int) {
+		if s.history == nil {
+			return
+		}
+		exchange := api.Exchange{
+			Format:          format.Name,
+			Id:              historyID,
+			Received:        received.Format(time.RFC3339Nano),
+			Remote:          clientAddress,
+			Response:        response,
+			ResponseHeaders: hdrs.ToMap(w.Header(), nil, nil),
+			Status:          status,
+		}
+		if fault != chaos.None {
+			name := string(fault)
+			exchange.Fault = &name
+		}
+		if !s.keepCreds {
+			exchange = history.Redact(exchange)
+		}
+		s.history.Add(exchange)
+	}
+
+	if fault != chaos.None && fault != chaos.Error {
+		// Record the exchange before the fault is injected, as it may never reach the client
+		record(status)
+		chaos.Inject(w, r, fault, status, body.Bytes())
+		return
+	}
+	// Record the status actually sent, e.g. 304 or 206 for conditional and range requests
+	sent := &statusWriter{ResponseWriter: w}
+	if cacheable {
+		s.cache.Serve(sent, r, body.Bytes())
+	} else {
+		sent.WriteHeader(status)
+		if _, err := sent.Write(body.Bytes()); err != nil {
+			logging.Errorf("Error writing response: %v", err)
+		}
+	}
+	record(sent.status)
+}
+
+// statusWriter is a http.ResponseWriter recording the status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/auth"
+	"github.com/fgiudici/headertrace/pkg/cache"
+	"github.com/fgiudici/headertrace/pkg/history"
+	"github.com/fgiudici/headertrace/pkg/jwt"
+	"github.com/fgiudici/headertrace/pkg/negotiate"
+	"github.com/fgiudici/headertrace/pkg/proxy"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// newTestServer returns a server with the default settings and the request history enabled.
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	srv := &server{}
+	var err error
+	if srv.tokens, err = jwt.New(jwt.Config{}); err != nil {
+		t.Fatalf("jwt.New() error = %v", err)
+	}
+	if srv.negotiator, err = negotiate.New(negotiate.Config{}); err != nil {
+		t.Fatalf("negotiate.New() error = %v", err)
+	}
+	if srv.history, err = history.New(10); err != nil {
+		t.Fatalf("history.New() error = %v", err)
+	}
+	return srv
+}
+
+// serve sends the request to the handler of the server, returning the response.
+func serve(handler http.Handler, method, target string, header http.Header) *http.Response {
+	req := httptest.NewRequest(method, target, strings.NewReader("{}"))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+// exchanges lists the recorded exchanges.
+func exchanges(t *testing.T, handler http.Handler, header http.Header) []api.ExchangeSummary {
+	t.Helper()
+	resp := serve(handler, http.MethodGet, "/_headertrace/requests", header)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /_headertrace/requests status = %d, want 200", resp.StatusCode)
+	}
+	var list api.ExchangeList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding the exchange list: %v", err)
+	}
+	return list.Exchanges
+}
+
+func TestEchoMethods(t *testing.T) {
+	handler := api.Handler(newTestServer(t))
+
+	methods := []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	for _, method := range methods {
+		for _, path := range []string{"/", "/hooks/github"} {
+			resp := serve(handler, method, path, http.Header{"User-Agent": {"GitHub-Hookshot/044aadd"}})
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("%s %s status = %d, want 200", method, path, resp.StatusCode)
+			}
+			if resp.Header.Get(history.IDHeader) == "" {
+				t.Fatalf("%s %s without the %s header", method, path, history.IDHeader)
+			}
+		}
+	}
+
+	list := exchanges(t, handler, nil)
+	if len(list) != 2*len(methods) {
+		t.Fatalf("recorded %d exchanges, want %d", len(list), 2*len(methods))
+	}
+	latest := list[0]
+	if latest.Method != http.MethodDelete || latest.Path != "/hooks/github" || latest.Status != http.StatusOK {
+		t.Fatalf("latest exchange = %+v, want DELETE /hooks/github with status 200", latest)
+	}
+	if latest.UserAgent == nil || *latest.UserAgent != "GitHub-Hookshot/044aadd" {
+		t.Fatalf("latest exchange User-Agent = %v, want GitHub-Hookshot/044aadd", latest.UserAgent)
+	}
+}
+
+func TestSnippetBody(t *testing.T) {
+	handler := api.Handler(newTestServer(t))
+
+	resp := serve(handler, http.MethodPost, "/hooks/github?format=curl", nil)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	if want := "--data-binary '{}'"; !strings.Contains(string(body), want) {
+		t.Fatalf("POST snippet = %s, expected to contain %q", body, want)
+	}
+}
+
+func TestHistoryStatus(t *testing.T) {
+	srv := newTestServer(t)
+	srv.cache = cache.NewValidator(time.Date(2025, 5, 4, 10, 0, 0, 0, time.UTC))
+	handler := api.Handler(srv)
+
+	resp := serve(handler, http.MethodGet, "/cached", nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("GET /cached status = %d, ETag = %q, want 200 with an ETag", resp.StatusCode, etag)
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{name: "not modified", header: http.Header{"If-None-Match": {etag}}, want: http.StatusNotModified},
+		{name: "partial content", header: http.Header{"Range": {"bytes=0-9"}}, want: http.StatusPartialContent},
+		{name: "range not satisfiable", header: http.Header{"Range": {"bytes=100000-"}}, want: http.StatusRequestedRangeNotSatisfiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(handler, http.MethodGet, "/cached", tt.header)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			latest := exchanges(t, handler, nil)[0]
+			if id := resp.Header.Get(history.IDHeader); fmt.Sprint(latest.Id) != id || latest.Status != tt.want {
+				t.Fatalf("latest exchange = %+v, want ID %s with status %d", latest, id, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheableHead(t *testing.T) {
+	srv := newTestServer(t)
+	srv.cache = cache.NewValidator(time.Date(2025, 5, 4, 10, 0, 0, 0, time.UTC))
+	handler := api.Handler(srv)
+
+	get := serve(handler, http.MethodGet, "/cached", nil)
+	head := serve(handler, http.MethodHead, "/cached", nil)
+	for _, name := range []string{"ETag", "Last-Modified"} {
+		if head.Header.Get(name) == "" || head.Header.Get(name) != get.Header.Get(name) {
+			t.Fatalf("HEAD %s = %q, want %q as for GET", name, head.Header.Get(name), get.Header.Get(name))
+		}
+	}
+	resp := serve(handler, http.MethodHead, "/cached", http.Header{"If-None-Match": {get.Header.Get("ETag")}})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional HEAD status = %d, want 304", resp.StatusCode)
+	}
+}
+
+func TestHistoryAuth(t *testing.T) {
+	srv := newTestServer(t)
+	var err error
+	if srv.auth, err = auth.New(auth.Config{Rules: []string{"/_headertrace:bearer"}, Tokens: []string{"t0ken"}}); err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+	handler := api.Handler(srv)
+	authorized := http.Header{"Authorization": {"Bearer t0ken"}}
+
+	serve(handler, http.MethodPost, "/hooks/github", http.Header{"Authorization": {"Bearer hook-secret"}, "Cookie": {"session=abc"}})
+
+	tests := []struct {
+		method string
+		target string
+	}{
+		{method: http.MethodGet, target: "/_headertrace/requests"},
+		{method: http.MethodGet, target: "/_headertrace/requests/1"},
+		{method: http.MethodDelete, target: "/_headertrace/requests"},
+	}
+	for _, tt := range tests {
+		resp := serve(handler, tt.method, tt.target, nil)
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Fatalf("%s %s without credentials status = %d, want 401 with a challenge", tt.method, tt.target, resp.StatusCode)
+		}
+	}
+
+	if list := exchanges(t, handler, authorized); len(list) != 1 {
+		t.Fatalf("recorded %d exchanges, want 1", len(list))
+	}
+	resp := serve(handler, http.MethodGet, "/_headertrace/requests/1", authorized)
+	var exchange api.Exchange
+	if err := json.NewDecoder(resp.Body).Decode(&exchange); err != nil {
+		t.Fatalf("decoding the exchange: %v", err)
+	}
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if got := exchange.Response.Headers[name]; got != "[redacted]" {
+			t.Fatalf("recorded %s = %q, want it redacted", name, got)
+		}
+	}
+
+	srv.keepCreds = true
+	serve(handler, http.MethodPost, "/hooks/github", http.Header{"Authorization": {"Bearer hook-secret"}})
+	resp = serve(handler, http.MethodGet, "/_headertrace/requests/2", authorized)
+	if err := json.NewDecoder(resp.Body).Decode(&exchange); err != nil {
+		t.Fatalf("decoding the exchange: %v", err)
+	}
+	if got := exchange.Response.Headers["Authorization"]; got != "Bearer hook-secret" {
+		t.Fatalf("recorded Authorization = %q with the credentials kept, want Bearer hook-secret", got)
+	}
+}
+
+func TestRemoteAddress(t *testing.T) {
+	resolver, err := proxy.NewResolver([]string{"loopback"})
+	if err != nil {
+		t.Fatalf("NewResolver() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		headers      http.Header
+		geo          *api.GeoInfo
+		want         string
+		unexpectedIP string
+	}{
+		{
+			name:       "uses the resolved address with Cf-Ipcountry",
+			remoteAddr: "127.0.0.1:5000",
+			headers: http.Header{
+				"CF-Connecting-IP": {"1.2.3.4"},
+				"Cf-Ipcountry":     {"US"},
+				"X-Forwarded-For":  {"1.2.3.4"},
+			},
+			want: "1.2.3.4(US) x-forwarded-for [127.0.0.1:5000] via cloudflare",
+		},
+		{
+			name:       "reports the edge providers and their country",
+			remoteAddr: "127.0.0.1:5000",
+			headers: http.Header{
+				"Cloudfront-Viewer-Country": {"DE"},
+				"X-Amz-Cf-Id":               {"ZJx1C3DHtFQTa3nUT8JgQm6nHBkYu9Ymq3bC8HCdyb0T7Ym6NeHuYA=="},
+				"X-Vercel-Id":               {"fra1::iad1::8vzbk-1700000000000-4d5f2e1a7c3b"},
+				"X-Forwarded-For":           {"1.2.3.4"},
+			},
+			want: "1.2.3.4(DE) x-forwarded-for [127.0.0.1:5000] via cloudfront via vercel/fra1",
+		},
+		{
+			name:       "prefers the GeoIP lookup to Cf-Ipcountry",
+			remoteAddr: "127.0.0.1:5000",
+			headers: http.Header{
+				"Cf-Ipcountry":    {"US"},
+				"X-Forwarded-For": {"1.2.3.4"},
+			},
+			geo:  &api.GeoInfo{Address: "1.2.3.4", Country: ptr("IT"), City: ptr("Milan"), Asn: ptr(3269), Organization: ptr("Telecom Italia")},
+			want: "1.2.3.4(IT, Milan, AS3269 Telecom Italia) x-forwarded-for [127.0.0.1:5000] via cloudflare",
+		},
+		{
+			name:       "locates the peer address",
+			remoteAddr: "192.168.1.1:8080",
+			geo:        &api.GeoInfo{Address: "192.168.1.1", Organization: ptr("Example LAN")},
+			want:       "192.168.1.1:8080(Example LAN)",
+		},
+		{
+			name:       "uses X-Real-IP set by a trusted proxy",
+			remoteAddr: "127.0.0.1:5000",
+			headers: http.Header{
+				"X-Real-Ip": {"5.6.7.8"},
+			},
+			want: "5.6.7.8 x-real-ip [127.0.0.1:5000]",
+		},
+		{
+			name:       "ignores the headers of untrusted peers",
+			remoteAddr: "192.168.1.1:8080",
+			headers: http.Header{
+				"CF-Connecting-IP": {"1.2.3.4"},
+				"Cf-Ipcountry":     {"US"},
+				"X-Real-Ip":        {"5.6.7.8"},
+				"X-Forwarded-For":  {"9.10.11.12"},
+			},
+			want:         "192.168.1.1:8080",
+			unexpectedIP: "9.10.11.12",
+		},
+		{
+			name:       "uses r.RemoteAddr when no proxy headers available",
+			remoteAddr: "127.0.0.1:8080",
+			headers:    http.Header{},
+			want:       "127.0.0.1:8080",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+
+			got := remoteAddress(req, resolver.Resolve(req), tt.geo)
+			if got != tt.want {
+				t.Fatalf("remoteAddress() = %q, want %q", got, tt.want)
+			}
+			if tt.unexpectedIP != "" && strings.Contains(got, tt.unexpectedIP) {
+				t.Fatalf("remoteAddress() = %q, expected not to contain spoofed IP %q", got, tt.unexpectedIP)
+			}
+		})
+	}
+}
+
+func TestIsPrivateHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "cf-connecting-ip", want: true},
+		{header: "fastly-client-ip", want: true},
+		{header: "x-amz-cf-id", want: true},
+		{header: "x-forwarded-for", want: true},
+		{header: "x-real-ip", want: true},
+		{header: "via", want: false},
+		{header: "x-custom", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := isPrivateHeader(tt.header); got != tt.want {
+				t.Fatalf("isPrivateHeader(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,11 +3,18 @@ module github.com/fgiudici/headertrace
 go 1.25
 
 require (
+	github.com/fxamacker/cbor/v2 v2.7.0
+	github.com/google/uuid v1.5.0
 	github.com/oapi-codegen/runtime v1.1.2
+	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/spf13/pflag v1.0.10
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/apapsch/go-jsonmerge/v2 v2.0.0 // indirect
-	github.com/google/uuid v1.5.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/logging"
+	"github.com/fgiudici/headertrace/pkg/pathprefix"
+)
+
+// Supported authentication schemes.
+const (
+	Basic        = "basic"
+	Digest       = "digest"
+	DigestSHA256 = "digest-sha256"
+	Bearer       = "bearer"
+)
+
+// nonceLifetime is the validity of the Digest nonces issued in the challenges.
+const nonceLifetime = 5 * time.Minute
+
+// Config holds the authentication challenge settings.
+type Config struct {
+	// Rules are the protected path prefixes in "prefix:scheme" format (e.g. "/admin:basic").
+	Rules []string
+	// Users are the credentials accepted by the Basic and Digest schemes in "user:password" format.
+	Users []string
+	// Tokens are the tokens accepted by the Bearer scheme.
+	Tokens []string
+	// Realm is the protection space advertised in the challenges.
+	Realm string
+}
+
+type rule struct {
+	prefix  string
+	schemes []string
+}
+
+// Authenticator challenges requests to protected path prefixes and checks their credentials.
+type Authenticator struct {
+	rules  []rule
+	users  map[string]string
+	tokens []string
+	realm  string
+	secret []byte
+}
+
+// New validates the configuration and returns the corresponding Authenticator.
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{users: map[string]string{}, realm: cfg.Realm, secret: make([]byte, 32)}
+	if _, err := rand.Read(a.secret); err != nil {
+		return nil, fmt.Errorf("cannot generate nonce secret: %w", err)
+	}
+
+	for _, r := range cfg.Rules {
+		prefix, scheme, ok := strings.Cut(r, ":")
+		prefix, scheme = strings.TrimSpace(prefix), strings.ToLower(strings.TrimSpace(scheme))
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid rule format '%s', expected '/prefix:scheme'", r)
+		}
+		if !slices.Contains([]string{Basic, Digest, DigestSHA256, Bearer}, scheme) {
+			return nil, fmt.Errorf("unsupported scheme '%s' in '%s' (supported: %s, %s, %s, %s)", scheme, r, Basic, Digest, DigestSHA256, Bearer)
+		}
+		a.addRule(prefix, scheme)
+	}
+
+	for _, u := range cfg.Users {
+		user, password, ok := strings.Cut(u, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("invalid user format '%s', expected 'user:password'", u)
+		}
+		a.users[user] = password
+	}
+	for _, t := range cfg.Tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			a.tokens = append(a.tokens, t)
+		}
+	}
+	return a, nil
+}
+
+func (a *Authenticator) addRule(prefix, scheme string) {
+	for i := range a.rules {
+		if a.rules[i].prefix == prefix {
+			a.rules[i].schemes = append(a.rules[i].schemes, scheme)
+			return
+		}
+	}
+	a.rules = append(a.rules, rule{prefix: prefix, schemes: []string{scheme}})
+	// Longest prefixes first, so that the most specific rule wins.
+	slices.SortStableFunc(a.rules, func(x, y rule) int { return len(y.prefix) - len(x.prefix) })
+}
+
+// Check verifies the credentials of requests to protected paths. It returns nil when the request
+// path is not protected; otherwise it returns the outcome of the check and, when the request is not
+// authenticated, adds the WWW-Authenticate challenges to the response headers.
+func (a *Authenticator) Check(w http.ResponseWriter, r *http.Request) *api.AuthInfo {
+	rl := a.match(r.URL.Path)
+	if rl == nil {
+		return nil
+	}
+	info := &api.AuthInfo{Prefix: rl.prefix, Schemes: rl.schemes, Realm: a.realm}
+
+	authz := r.Header.Get("Authorization")
+	stale := false
+	if authz == "" {
+		info.Reason = "missing Authorization header"
+	} else {
+		scheme, params, _ := strings.Cut(authz, " ")
+		info.Scheme = &scheme
+		var user string
+		switch strings.ToLower(scheme) {
+		case Basic:
+			user, info.Reason = a.checkBasic(rl, params)
+		case Digest:
+			user, info.Reason, stale = a.checkDigest(rl, r.Method, r.URL.RequestURI(), params)
+		case Bearer:
+			info.Reason = a.checkBearer(rl, params)
+		default:
+			info.Reason = fmt.Sprintf("unsupported scheme '%s'", scheme)
+		}
+		if user != "" {
+			info.User = &user
+		}
+		info.Authenticated = info.Reason == ""
+	}
+
+	if info.Authenticated {
+		info.Reason = "valid credentials"
+		logging.Debugf("Auth: request to '%s' authenticated with scheme %s", r.URL.Path, *info.Scheme)
+		return info
+	}
+	sentScheme, _, _ := strings.Cut(authz, " ")
+	challenges := a.challenges(rl, sentScheme, stale)
+	for _, c := range challenges {
+		w.Header().Add("WWW-Authenticate", c)
+	}
+	info.Challenges = &challenges
+	logging.Debugf("Auth: request to '%s' not authenticated: %s", r.URL.Path, info.Reason)
+	return info
+}
+
+// Protects checks if the path is protected by one of the rules. It returns false for a nil
+// Authenticator.
+func (a *Authenticator) Protects(path string) bool {
+	return a != nil && a.match(path) != nil
+}
+
+func (a *Authenticator) match(path string) *rule {
+	for i := range a.rules {
+		if pathprefix.Match(path, a.rules[i].prefix) {
+			return &a.rules[i]
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) checkBasic(rl *rule, params string) (string, string) {
+	if !slices.Contains(rl.schemes, Basic) {
+		return "", "scheme Basic not accepted for this path"
+	}
+	req := &http.Request{Header: http.Header{"Authorization": {"Basic " + params}}}
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return "", "malformed Basic credentials"
+	}
+	expected, found := a.users[user]
+	if !found || subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 {
+		return user, fmt.Sprintf("invalid password for user '%s'", user)
+	}
+	return user, ""
+}
+
+func (a *Authenticator) checkBearer(rl *rule, token string) string {
+	if !slices.Contains(rl.schemes, Bearer) {
+		return "scheme Bearer not accepted for this path"
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "empty Bearer token"
+	}
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return ""
+		}
+	}
+	return "invalid Bearer token"
+}
+
+// checkDigest verifies Digest credentials (RFC 7616). It returns the user name, the reason
+// of the failure (empty on success) and whether the failure is due to a stale nonce. The uri
+// parameter must match the request target, so that responses can't be replayed on other paths.
+func (a *Authenticator) checkDigest(rl *rule, method, target, params string) (string, string, bool) {
+	p := parseParams(params)
+	user := p["username"]
+
+	algorithm := p["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	var newHash func() hash.Hash
+	switch {
+	case strings.EqualFold(algorithm, "MD5") && slices.Contains(rl.schemes, Digest):
+		newHash = md5.New
+	case strings.EqualFold(algorithm, "SHA-256") && slices.Contains(rl.schemes, DigestSHA256):
+		newHash = sha256.New
+	default:
+		return user, fmt.Sprintf("Digest algorithm '%s' not accepted for this path", algorithm), false
+	}
+
+	for _, k := range []string{"username", "realm", "nonce", "uri", "response"} {
+		if p[k] == "" {
+			return user, fmt.Sprintf("missing Digest parameter '%s'", k), false
+		}
+	}
+	if p["uri"] != target {
+		return user, fmt.Sprintf("Digest uri '%s' does not match the request target '%s'", p["uri"], target), false
+	}
+	if p["realm"] != a.realm {
+		return user, fmt.Sprintf("realm mismatch: got '%s', expected '%s'", p["realm"], a.realm), false
+	}
+	if reason, stale := a.checkNonce(p["nonce"]); reason != "" {
+		return user, reason, stale
+	}
+	password, found := a.users[user]
+	if !found {
+		return user, fmt.Sprintf("unknown user '%s'", user), false
+	}
+
+	h := func(s string) string {
+		d := newHash()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+	ha1 := h(user + ":" + a.realm + ":" + password)
+	ha2 := h(method + ":" + p["uri"])
+	var expected string
+	switch p["qop"] {
+	case "auth":
+		expected = h(strings.Join([]string{ha1, p["nonce"], p["nc"], p["cnonce"], "auth", ha2}, ":"))
+	case "":
+		expected = h(ha1 + ":" + p["nonce"] + ":" + ha2)
+	default:
+		return user, fmt.Sprintf("unsupported qop '%s'", p["qop"]), false
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(p["response"]))) != 1 {
+		return user, fmt.Sprintf("invalid Digest response for user '%s'", user), false
+	}
+	return user, "", false
+}
+
+// nonce returns a stateless Digest nonce: the issue time signed with the server secret.
+func (a *Authenticator) nonce(issued time.Time) string {
+	ts := strconv.FormatInt(issued.Unix(), 16)
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(ts))
+	return ts + "-" + hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+func (a *Authenticator) checkNonce(nonce string) (string, bool) {
+	ts, _, _ := strings.Cut(nonce, "-")
+	sec, err := strconv.ParseInt(ts, 16, 64)
+	if err != nil || !hmac.Equal([]byte(nonce), []byte(a.nonce(time.Unix(sec, 0)))) {
+		return "invalid Digest nonce", false
+	}
+	if time.Since(time.Unix(sec, 0)) > nonceLifetime {
+		return "stale Digest nonce", true
+	}
+	return "", false
+}
+
+// challenges returns the WWW-Authenticate values for the schemes accepted by the rule.
+func (a *Authenticator) challenges(rl *rule, sentScheme string, stale bool) []string {
+	var challenges []string
+	nonce := a.nonce(time.Now())
+	opaque := hex.EncodeToString([]byte(rl.prefix))
+	for _, scheme := range rl.schemes {
+		switch scheme {
+		case Basic:
+			challenges = append(challenges, fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, a.realm))
+		case Digest, DigestSHA256:
+			algorithm := "MD5"
+			if scheme == DigestSHA256 {
+				algorithm = "SHA-256"
+			}
+			c := fmt.Sprintf(`Digest realm=%q, qop="auth", algorithm=%s, nonce=%q, opaque=%q`, a.realm, algorithm, nonce, opaque)
+			if stale {
+				c += ", stale=true"
+			}
+			challenges = append(challenges, c)
+		case Bearer:
+			c := fmt.Sprintf(`Bearer realm=%q`, a.realm)
+			if strings.EqualFold(sentScheme, Bearer) {
+				c += `, error="invalid_token"`
+			}
+			challenges = append(challenges, c)
+		}
+	}
+	return challenges
+}
+
+// parseParams parses the comma separated auth-param list of an Authorization header.
+func parseParams(s string) map[string]string {
+	params := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimSpace(rest)
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for ; end < len(rest) && rest[end] != '"'; end++ {
+				if rest[end] == '\\' {
+					end++
+				}
+			}
+			value = strings.ReplaceAll(rest[1:min(end, len(rest))], `\`, "")
+			rest = rest[min(end+1, len(rest)):]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+			rest = "," + rest
+		}
+		params[key] = value
+		_, rest, _ = strings.Cut(rest, ",")
+		s = rest
+	}
+	return params
+}
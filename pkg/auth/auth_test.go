@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "valid rules",
+			cfg:  Config{Rules: []string{"/admin:basic", "/admin:digest", "/api:Bearer"}, Users: []string{"alice:secret"}},
+		},
+		{
+			name:    "missing scheme",
+			cfg:     Config{Rules: []string{"/admin"}},
+			wantErr: true,
+		},
+		{
+			name:    "prefix without leading slash",
+			cfg:     Config{Rules: []string{"admin:basic"}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported scheme",
+			cfg:     Config{Rules: []string{"/admin:ntlm"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid user",
+			cfg:     Config{Rules: []string{"/admin:basic"}, Users: []string{"alice"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// digestAuthorization computes the Authorization header value a client would send in reply to a Digest challenge.
+func digestAuthorization(newHash func() hash.Hash, algorithm, user, password, realm, nonce, method, uri string) string {
+	h := func(s string) string {
+		d := newHash()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+	ha1 := h(user + ":" + realm + ":" + password)
+	ha2 := h(method + ":" + uri)
+	response := h(strings.Join([]string{ha1, nonce, "00000001", "0a4f113b", "auth", ha2}, ":"))
+	return fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, qop=auth, nc=00000001, cnonce="0a4f113b", response="%s"`,
+		user, realm, nonce, uri, algorithm, response)
+}
+
+func TestCheck(t *testing.T) {
+	a, err := New(Config{
+		Rules:  []string{"/basic:basic", "/digest:digest", "/sha:digest-sha256", "/token:bearer", "/token/basic:basic"},
+		Users:  []string{"alice:secret"},
+		Tokens: []string{"t0ken"},
+		Realm:  "test",
+	})
+	if err != nil {
+		t.Fatalf("New() unexpected error = %v", err)
+	}
+	nonce := a.nonce(time.Now())
+	staleNonce := a.nonce(time.Now().Add(-2 * nonceLifetime))
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+
+	tests := []struct {
+		name              string
+		path              string
+		authorization     string
+		wantProtected     bool
+		wantAuthenticated bool
+		wantChallenge     string
+	}{
+		{
+			name: "unprotected path",
+			path: "/public",
+		},
+		{
+			name: "prefix matched on a segment boundary only",
+			path: "/basicauth",
+		},
+		{
+			name:          "missing credentials",
+			path:          "/basic/foo",
+			wantProtected: true,
+			wantChallenge: `Basic realm="test"`,
+		},
+		{
+			name:              "valid basic credentials",
+			path:              "/basic",
+			authorization:     basic("alice", "secret"),
+			wantProtected:     true,
+			wantAuthenticated: true,
+		},
+		{
+			name:          "invalid basic password",
+			path:          "/basic",
+			authorization: basic("alice", "wrong"),
+			wantProtected: true,
+			wantChallenge: `Basic realm="test"`,
+		},
+		{
+			name:              "valid digest MD5 credentials",
+			path:              "/digest",
+			authorization:     digestAuthorization(md5.New, "MD5", "alice", "secret", "test", nonce, http.MethodGet, "/digest"),
+			wantProtected:     true,
+			wantAuthenticated: true,
+		},
+		{
+			name:          "invalid digest password",
+			path:          "/digest",
+			authorization: digestAuthorization(md5.New, "MD5", "alice", "wrong", "test", nonce, http.MethodGet, "/digest"),
+			wantProtected: true,
+			wantChallenge: "algorithm=MD5",
+		},
+		{
+			name:          "stale digest nonce",
+			path:          "/digest",
+			authorization: digestAuthorization(md5.New, "MD5", "alice", "secret", "test", staleNonce, http.MethodGet, "/digest"),
+			wantProtected: true,
+			wantChallenge: "stale=true",
+		},
+		{
+			name:          "forged digest nonce",
+			path:          "/digest",
+			authorization: digestAuthorization(md5.New, "MD5", "alice", "secret", "test", "1-abc", http.MethodGet, "/digest"),
+			wantProtected: true,
+			wantChallenge: "algorithm=MD5",
+		},
+		{
+			name:          "digest response computed for another path",
+			path:          "/digest/users",
+			authorization: digestAuthorization(md5.New, "MD5", "alice", "secret", "test", nonce, http.MethodGet, "/digest/other"),
+			wantProtected: true,
+			wantChallenge: "algorithm=MD5",
+		},
+		{
+			name:              "valid digest credentials with query",
+			path:              "/digest?a=1",
+			authorization:     digestAuthorization(md5.New, "MD5", "alice", "secret", "test", nonce, http.MethodGet, "/digest?a=1"),
+			wantProtected:     true,
+			wantAuthenticated: true,
+		},
+		{
+			name:              "valid digest SHA-256 credentials",
+			path:              "/sha",
+			authorization:     digestAuthorization(sha256.New, "SHA-256", "alice", "secret", "test", nonce, http.MethodGet, "/sha"),
+			wantProtected:     true,
+			wantAuthenticated: true,
+		},
+		{
+			name:          "digest MD5 not accepted on SHA-256 path",
+			path:          "/sha",
+			authorization: digestAuthorization(md5.New, "MD5", "alice", "secret", "test", nonce, http.MethodGet, "/sha"),
+			wantProtected: true,
+			wantChallenge: "algorithm=SHA-256",
+		},
+		{
+			name:              "valid bearer token",
+			path:              "/token",
+			authorization:     "Bearer t0ken",
+			wantProtected:     true,
+			wantAuthenticated: true,
+		},
+		{
+			name:          "invalid bearer token",
+			path:          "/token",
+			authorization: "Bearer wrong",
+			wantProtected: true,
+			wantChallenge: `error="invalid_token"`,
+		},
+		{
+			name:          "longest prefix wins",
+			path:          "/token/basic",
+			authorization: "Bearer t0ken",
+			wantProtected: true,
+			wantChallenge: `Basic realm="test"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			info := a.Check(rec, req)
+			if (info != nil) != tt.wantProtected {
+				t.Fatalf("Check() = %v, want protected %v", info, tt.wantProtected)
+			}
+			if info == nil {
+				return
+			}
+			if info.Authenticated != tt.wantAuthenticated {
+				t.Fatalf("Check() authenticated = %v, want %v (reason: %s)", info.Authenticated, tt.wantAuthenticated, info.Reason)
+			}
+			challenges := strings.Join(rec.Header().Values("WWW-Authenticate"), "\n")
+			if tt.wantAuthenticated && challenges != "" {
+				t.Fatalf("Check() sent challenges %q for an authenticated request", challenges)
+			}
+			if !strings.Contains(challenges, tt.wantChallenge) {
+				t.Fatalf("Check() challenges = %q, expected to contain %q", challenges, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestProtects(t *testing.T) {
+	a, err := New(Config{Rules: []string{"/_headertrace:bearer"}, Tokens: []string{"t0ken"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !a.Protects("/_headertrace/requests") {
+		t.Fatalf("Protects(/_headertrace/requests) = false, want true")
+	}
+	if a.Protects("/hooks") {
+		t.Fatalf("Protects(/hooks) = true, want false")
+	}
+	var none *Authenticator
+	if none.Protects("/_headertrace/requests") {
+		t.Fatalf("nil Protects() = true, want false")
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	got := parseParams(`username="alice", realm="my, realm", nc=00000001, qop=auth, response="abc"`)
+	want := map[string]string{"username": "alice", "realm": "my, realm", "nc": "00000001", "qop": "auth", "response": "abc"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("parseParams()[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/fgiudici/headertrace/pkg/logging"
+)
+
+// Validator makes echo responses cacheable: it assigns them stable validators (ETag and Last-Modified)
+// and answers conditional (If-None-Match, If-Modified-Since) and range requests.
+//
+// The echoed body changes with the request headers, so validators cannot be derived from the body
+// without breaking revalidation through proxies adding their own headers: the ETag identifies the
+// resource (request URI and representation) and Last-Modified is the server start time. As different
+// bodies share the same ETag, it is a weak one: it validates cached responses, but not If-Range.
+// Neither does Last-Modified, as the bodies change without it: ranges with If-Range get the full body.
+type Validator struct {
+	modTime time.Time
+}
+
+// NewValidator returns a Validator whose resources are last modified at modTime.
+func NewValidator(modTime time.Time) *Validator {
+	// HTTP dates have a one second resolution.
+	return &Validator{modTime: modTime.UTC().Truncate(time.Second)}
+}
+
+// ETag returns the weak entity tag of the resource identified by the request URI and the
+// optional representation variants (e.g. the content type of the response).
+func (v *Validator) ETag(r *http.Request, variants ...string) string {
+	h := sha256.New()
+	h.Write([]byte(v.modTime.Format(time.RFC3339)))
+	h.Write([]byte(r.URL.RequestURI()))
+	for _, variant := range variants {
+		h.Write([]byte{0})
+		h.Write([]byte(variant))
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:20] + `"`
+}
+
+// SetHeaders adds the validators and the Accept-Ranges header to the response headers.
+func (v *Validator) SetHeaders(w http.ResponseWriter, r *http.Request, variants ...string) {
+	w.Header().Set("ETag", v.ETag(r, variants...))
+	w.Header().Set("Last-Modified", v.modTime.Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+}
+
+// Serve writes the body honoring conditional and range request headers: it replies with
+// 304 Not Modified, 206 Partial Content (multipart/byteranges for multiple ranges),
+// 416 Range Not Satisfiable or 200 OK. SetHeaders must be called before Serve.
+func (v *Validator) Serve(w http.ResponseWriter, r *http.Request, body []byte) {
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		logging.Debugf("Conditional request: If-None-Match=%q If-Modified-Since=%q (ETag %s)",
+			r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since"), w.Header().Get("ETag"))
+	}
+	if rng := r.Header.Get("Range"); rng != "" {
+		logging.Debugf("Range request: %q (%d bytes)", rng, len(body))
+		// No strong validator can satisfy If-Range: http.ServeContent accepts the date,
+		// that would combine ranges of different bodies.
+		if r.Header.Get("If-Range") != "" {
+			logging.Debugf("Range ignored: If-Range %q needs a strong validator", r.Header.Get("If-Range"))
+			r = r.Clone(r.Context())
+			r.Header.Del("Range")
+		}
+	}
+	http.ServeContent(w, r, "", v.modTime, bytes.NewReader(body))
+}
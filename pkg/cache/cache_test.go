@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	v := NewValidator(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	other := NewValidator(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	sameReq := httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	sameReq.Header.Set("X-Forwarded-For", "10.0.0.1")
+	otherReq := httptest.NewRequest(http.MethodGet, "http://example.com/bar", nil)
+
+	if v.ETag(req) != v.ETag(sameReq) {
+		t.Fatalf("ETag() changes with request headers: %s != %s", v.ETag(req), v.ETag(sameReq))
+	}
+	if v.ETag(req) == v.ETag(otherReq) {
+		t.Fatalf("ETag() does not change with the request URI: %s", v.ETag(req))
+	}
+	if v.ETag(req, "application/json") == v.ETag(req, "text/html") {
+		t.Fatalf("ETag() does not change with the representation variant: %s", v.ETag(req, "text/html"))
+	}
+	if v.ETag(req) == other.ETag(req) {
+		t.Fatalf("ETag() does not change with the modification time: %s", v.ETag(req))
+	}
+	if etag := v.ETag(req); !strings.HasPrefix(etag, `W/"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("ETag() = %s, expected a quoted weak entity tag", etag)
+	}
+}
+
+func TestServe(t *testing.T) {
+	modTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	v := NewValidator(modTime)
+	body := []byte("0123456789")
+	etag := v.ETag(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	tests := []struct {
+		name            string
+		headers         http.Header
+		wantStatus      int
+		wantBody        string
+		wantContentType string
+	}{
+		{
+			name:       "plain request",
+			headers:    http.Header{},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+		{
+			name:       "If-None-Match matches",
+			headers:    http.Header{"If-None-Match": {etag}},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "If-None-Match does not match",
+			headers:    http.Header{"If-None-Match": {`"other"`}},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+		{
+			name:       "If-Modified-Since not modified",
+			headers:    http.Header{"If-Modified-Since": {modTime.Add(time.Hour).Format(http.TimeFormat)}},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "If-Modified-Since modified",
+			headers:    http.Header{"If-Modified-Since": {modTime.Add(-time.Hour).Format(http.TimeFormat)}},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+		{
+			name:       "single range",
+			headers:    http.Header{"Range": {"bytes=2-4"}},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "234",
+		},
+		{
+			name:            "multiple ranges",
+			headers:         http.Header{"Range": {"bytes=0-1,8-"}},
+			wantStatus:      http.StatusPartialContent,
+			wantContentType: "multipart/byteranges",
+		},
+		{
+			name:       "unsatisfiable range",
+			headers:    http.Header{"Range": {"bytes=20-30"}},
+			wantStatus: http.StatusRequestedRangeNotSatisfiable,
+		},
+		{
+			name:       "If-Range with the weak ETag returns full content",
+			headers:    http.Header{"Range": {"bytes=2-4"}, "If-Range": {etag}},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+		{
+			name:       "If-Range with Last-Modified returns full content",
+			headers:    http.Header{"Range": {"bytes=2-4"}, "If-Range": {modTime.Format(http.TimeFormat)}},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+		{
+			name:       "If-Range mismatch returns full content",
+			headers:    http.Header{"Range": {"bytes=2-4"}, "If-Range": {`"other"`}},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.Header = tt.headers
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", "text/plain")
+
+			v.SetHeaders(rec, req)
+			v.Serve(rec, req, body)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Serve() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Fatalf("Serve() body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if tt.wantContentType != "" && !strings.HasPrefix(rec.Header().Get("Content-Type"), tt.wantContentType) {
+				t.Fatalf("Serve() Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.wantContentType)
+			}
+			// Error responses drop the validators.
+			if rec.Code < http.StatusBadRequest && rec.Header().Get("ETag") != etag {
+				t.Fatalf("Serve() ETag = %q, want %q", rec.Header().Get("ETag"), etag)
+			}
+		})
+	}
+}
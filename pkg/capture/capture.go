@@ -0,0 +1,170 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultLimit is the default maximum number of bytes buffered for each connection.
+const DefaultLimit = 1 << 20
+
+type contextKey struct{}
+
+// Listener wraps a net.Listener recording the bytes read from the accepted connections,
+// so that the request headers can be retrieved as they were received on the wire.
+type Listener struct {
+	net.Listener
+	limit int
+}
+
+// NewListener returns a Listener buffering at most limit bytes for each connection.
+func NewListener(l net.Listener, limit int) *Listener {
+	return &Listener{Listener: l, limit: limit}
+}
+
+// Accept implements net.Listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: c, limit: l.limit}, nil
+}
+
+// Conn is a net.Conn recording the bytes read.
+type Conn struct {
+	net.Conn
+	limit int
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Read implements net.Conn.
+func (c *Conn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		c.buf = append(c.buf, p[:n]...)
+		if excess := len(c.buf) - c.limit; excess > 0 {
+			c.buf = c.buf[excess:]
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// Unwrap returns the wrapped connection, e.g. to access the TCP connection options.
+func (c *Conn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+// ConnContext stores the connection in the context: it is meant to be used as http.Server.ConnContext.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if cc, ok := c.(*Conn); ok {
+		return context.WithValue(ctx, contextKey{}, cc)
+	}
+	return ctx
+}
+
+// FromRequest returns the connection the request was received from, if recorded.
+func FromRequest(r *http.Request) (*Conn, bool) {
+	c, ok := r.Context().Value(contextKey{}).(*Conn)
+	return c, ok
+}
+
+// Header returns the raw header block of the request (request line, header fields and the
+// terminating empty line) as received on the wire, and discards the recorded bytes up to its end.
+// Returns nil if the request header block cannot be found (e.g. for HTTP/2 requests).
+func (c *Conn) Header(r *http.Request) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	requestLine := []byte(r.Method + " " + r.RequestURI + " ")
+	start := -1
+	for offset := 0; offset < len(c.buf); {
+		i := bytes.Index(c.buf[offset:], requestLine)
+		if i < 0 {
+			break
+		}
+		i += offset
+		if i == 0 || c.buf[i-1] == '\n' {
+			start = i
+			break
+		}
+		offset = i + 1
+	}
+	if start < 0 {
+		return nil
+	}
+
+	end := headerEnd(c.buf[start:])
+	if end < 0 {
+		return nil
+	}
+	end += start
+	header := bytes.Clone(c.buf[start:end])
+	c.buf = c.buf[end:]
+	return header
+}
+
+// Field is a header field as received on the wire.
+type Field struct {
+	// Name is the field name, including any whitespace before the colon.
+	Name string
+	// Value is the field value, with the surrounding whitespace kept. The lines continuing
+	// a folded value are joined by a space.
+	Value string
+	// Folded reports whether the value is continued on the next lines (obsolete line folding).
+	Folded bool
+}
+
+// ParseHeader returns the fields of the raw header block returned by Header, in the order
+// they were received.
+func ParseHeader(raw []byte) []Field {
+	var fields []Field
+	lines := strings.Split(string(raw), "\n")
+	for _, line := range lines[1:] {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].Value += " " + strings.Trim(line, " \t")
+			fields[len(fields)-1].Folded = true
+			continue
+		}
+		name, value, _ := strings.Cut(line, ":")
+		fields = append(fields, Field{Name: name, Value: value})
+	}
+	return fields
+}
+
+// headerEnd returns the index following the empty line terminating the header block, or -1.
+// Both CRLF and bare LF line endings are accepted, as Go's HTTP server does.
+func headerEnd(b []byte) int {
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\n' {
+			continue
+		}
+		switch {
+		case i+1 < len(b) && b[i+1] == '\n':
+			return i + 2
+		case i+2 < len(b) && b[i+1] == '\r' && b[i+2] == '\n':
+			return i + 3
+		}
+	}
+	return -1
+}
+
+// Pending returns the bytes recorded after the last header block claimed by Header: the request
+// body read so far and any pipelined request already read ahead by the server.
+func (c *Conn) Pending() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.buf)
+}
@@ -0,0 +1,143 @@
+package capture
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	wire := "POST /upload HTTP/1.1\r\nHost: example.com\r\nContent-Length: 30\r\n\r\n" +
+		"GET /fake HTTP/1.1\r\nX: y\r\n\r\n" + // body looking like a request
+		"GET /next?a=1 HTTP/1.1\nhost: example.com\nX-Lower: v\n\n" +
+		"GET /fake HTTP/1.1\r\nHost: example.com\r\n\r\n"
+
+	c := &Conn{limit: DefaultLimit, buf: []byte(wire)}
+	tests := []struct {
+		method string
+		uri    string
+		want   string
+	}{
+		{
+			method: http.MethodPost,
+			uri:    "/upload",
+			want:   "POST /upload HTTP/1.1\r\nHost: example.com\r\nContent-Length: 30\r\n\r\n",
+		},
+		{
+			method: http.MethodGet,
+			uri:    "/next?a=1",
+			want:   "GET /next?a=1 HTTP/1.1\nhost: example.com\nX-Lower: v\n\n",
+		},
+		{
+			method: http.MethodGet,
+			uri:    "/fake",
+			want:   "GET /fake HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		},
+		{
+			method: http.MethodGet,
+			uri:    "/missing",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			req := &http.Request{Method: tt.method, RequestURI: tt.uri}
+			if got := string(c.Header(req)); got != tt.want {
+				t.Fatalf("Header() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() unexpected error = %v", err)
+	}
+	cl := NewListener(l, 64)
+	defer cl.Close()
+
+	wire := "GET / HTTP/1.1\r\nHost: example.com\r\nX-Padding: " + strings.Repeat("a", 100) + "\r\n\r\n"
+	go func() {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer client.Close()
+		_, _ = client.Write([]byte(wire))
+	}()
+
+	conn, err := cl.Accept()
+	if err != nil {
+		t.Fatalf("Accept() unexpected error = %v", err)
+	}
+	defer conn.Close()
+	if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+		t.Fatalf("ReadRequest() unexpected error = %v", err)
+	}
+
+	c := conn.(*Conn)
+	if len(c.buf) != 64 {
+		t.Fatalf("recorded %d bytes, want the limit of 64", len(c.buf))
+	}
+	if !strings.HasSuffix(wire, string(c.buf)) {
+		t.Fatalf("recorded %q, want the last bytes of %q", c.buf, wire)
+	}
+}
+
+func TestPending(t *testing.T) {
+	wire := "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nhelloGET /next HTTP/1.1\r\n"
+	c := &Conn{limit: DefaultLimit, buf: []byte(wire)}
+	if got := string(c.Pending()); got != wire {
+		t.Fatalf("Pending() = %q, want %q", got, wire)
+	}
+	c.Header(&http.Request{Method: http.MethodPost, RequestURI: "/"})
+	if got, want := string(c.Pending()), "helloGET /next HTTP/1.1\r\n"; got != want {
+		t.Fatalf("Pending() = %q, want %q", got, want)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []Field
+	}{
+		{
+			name: "CRLF line endings",
+			raw:  "GET / HTTP/1.1\r\nHost: example.com\r\nX-Padded:\t value \r\n\r\n",
+			want: []Field{{Name: "Host", Value: " example.com"}, {Name: "X-Padded", Value: "\t value "}},
+		},
+		{
+			name: "bare LF line endings",
+			raw:  "GET / HTTP/1.1\nhost: example.com\n\n",
+			want: []Field{{Name: "host", Value: " example.com"}},
+		},
+		{
+			name: "folded value",
+			raw:  "GET / HTTP/1.1\r\nX-Folded: a\r\n  b\r\n\tc\r\n\r\n",
+			want: []Field{{Name: "X-Folded", Value: " a b c", Folded: true}},
+		},
+		{
+			name: "whitespace before the colon",
+			raw:  "GET / HTTP/1.1\r\nTransfer-Encoding : chunked\r\n\r\n",
+			want: []Field{{Name: "Transfer-Encoding ", Value: " chunked"}},
+		},
+		{
+			name: "no fields",
+			raw:  "GET / HTTP/1.1\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHeader([]byte(tt.raw)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseHeader() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package chaos
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/logging"
+	"github.com/fgiudici/headertrace/pkg/pathprefix"
+)
+
+// Fault is a misbehavior the chaos engine can inject in a response.
+type Fault string
+
+const (
+	// None means no fault is injected.
+	None Fault = ""
+	// Error replies with a random 5xx status code.
+	Error Fault = "error"
+	// Reset resets the TCP connection without sending any response.
+	Reset Fault = "reset"
+	// Truncate closes the connection in the middle of the response body.
+	Truncate Fault = "truncate"
+	// BadLength sends a Content-Length header not matching the response body.
+	BadLength Fault = "bad-length"
+	// Hang never replies, until the client gives up.
+	Hang Fault = "hang"
+)
+
+// Faults lists all the supported faults, in evaluation order.
+var Faults = []Fault{Error, Reset, Truncate, BadLength, Hang}
+
+var errorStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+type route struct {
+	prefix        string
+	probabilities map[Fault]float64
+}
+
+// Engine injects faults in responses according to per-route probabilities.
+type Engine struct {
+	routes   []route
+	requests atomic.Int64
+	injected map[Fault]*atomic.Int64
+}
+
+// New parses the chaos rules and returns the corresponding Engine.
+// Rules are in "[/prefix:]fault=probability" format: rules without a prefix apply to all paths,
+// while rules with a prefix apply to the matching paths only, the longest matching prefix winning.
+func New(rules []string) (*Engine, error) {
+	e := &Engine{injected: map[Fault]*atomic.Int64{}}
+	for _, f := range Faults {
+		e.injected[f] = &atomic.Int64{}
+	}
+
+	probabilities := map[string]map[Fault]float64{}
+	for _, r := range rules {
+		prefix, spec := "/", r
+		if strings.HasPrefix(r, "/") {
+			var ok bool
+			if prefix, spec, ok = strings.Cut(r, ":"); !ok {
+				return nil, fmt.Errorf("invalid rule format '%s', expected '[/prefix:]fault=probability'", r)
+			}
+		}
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule format '%s', expected '[/prefix:]fault=probability'", r)
+		}
+		fault := Fault(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(Faults, fault) {
+			return nil, fmt.Errorf("unknown fault '%s' in '%s' (supported: %v)", name, r, Faults)
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid probability '%s' in '%s', expected a number between 0 and 1", value, r)
+		}
+		prefix = strings.TrimSpace(prefix)
+		if probabilities[prefix] == nil {
+			probabilities[prefix] = map[Fault]float64{}
+		}
+		probabilities[prefix][fault] = p
+	}
+
+	for prefix, faults := range probabilities {
+		total := 0.0
+		for _, p := range faults {
+			total += p
+		}
+		if total > 1 {
+			return nil, fmt.Errorf("fault probabilities for '%s' add up to %g, more than 1", prefix, total)
+		}
+		e.routes = append(e.routes, route{prefix: prefix, probabilities: faults})
+	}
+	// Longest prefixes first, so that the most specific route wins.
+	slices.SortFunc(e.routes, func(x, y route) int { return len(y.prefix) - len(x.prefix) })
+	return e, nil
+}
+
+// Pick rolls the dice for the request and returns the fault to inject, if any.
+func (e *Engine) Pick(r *http.Request) Fault {
+	e.requests.Add(1)
+	for _, rt := range e.routes {
+		if !pathprefix.Match(r.URL.Path, rt.prefix) {
+			continue
+		}
+		roll := rand.Float64()
+		for _, f := range Faults {
+			if roll -= rt.probabilities[f]; roll < 0 {
+				e.injected[f].Add(1)
+				logging.Infof("Chaos: injecting fault '%s' for %s %s", f, r.Method, r.URL.Path)
+				return f
+			}
+		}
+		return None
+	}
+	return None
+}
+
+// ErrorStatus returns a random 5xx status code for the Error fault.
+func ErrorStatus() int {
+	return errorStatuses[rand.IntN(len(errorStatuses))]
+}
+
+// Inject writes the response misbehaving as required by the fault. It must not be called
+// for the None and Error faults, which are handled as regular responses.
+func Inject(w http.ResponseWriter, r *http.Request, fault Fault, status int, body []byte) {
+	switch fault {
+	case Hang:
+		<-r.Context().Done()
+		logging.Debugf("Chaos: client gave up on hanging request: %v", r.Context().Err())
+	case Reset:
+		conn, _, err := hijack(w)
+		if err != nil {
+			logging.Debugf("Chaos: cannot hijack connection (%v), aborting the response", err)
+			panic(http.ErrAbortHandler)
+		}
+		if tcp, ok := unwrap(conn).(*net.TCPConn); ok {
+			// Discard unsent data and send a RST instead of a FIN on close.
+			_ = tcp.SetLinger(0)
+		}
+		conn.Close()
+	case Truncate:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		_, _ = w.Write(body[:len(body)/2])
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		// Aborting the handler closes the connection before the declared length is sent.
+		panic(http.ErrAbortHandler)
+	case BadLength:
+		conn, buf, err := hijack(w)
+		if err != nil {
+			logging.Debugf("Chaos: cannot hijack connection (%v), aborting the response", err)
+			panic(http.ErrAbortHandler)
+		}
+		defer conn.Close()
+		// Declare half of the body length: clients read a well framed but truncated body.
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)/2))
+		w.Header().Set("Connection", "close")
+		fmt.Fprintf(buf, "HTTP/1.1 %03d %s\r\n", status, http.StatusText(status))
+		_ = w.Header().Write(buf)
+		buf.WriteString("\r\n")
+		_, _ = buf.Write(body)
+		if err := buf.Flush(); err != nil {
+			logging.Debugf("Chaos: error writing response with bad Content-Length: %v", err)
+		}
+	default:
+		logging.Errorf("Chaos: unexpected fault '%s'", fault)
+	}
+}
+
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection does not support hijacking")
+	}
+	return hj.Hijack()
+}
+
+// unwrap returns the connection wrapped by the listener, such as capture.Conn, if any.
+func unwrap(conn net.Conn) net.Conn {
+	for {
+		wrapper, ok := conn.(interface{ Unwrap() net.Conn })
+		if !ok {
+			return conn
+		}
+		conn = wrapper.Unwrap()
+	}
+}
+
+// Stats returns the counters of the evaluated requests and of the injected faults.
+func (e *Engine) Stats() api.ChaosStats {
+	injected := make(map[string]int64, len(e.injected))
+	for f, c := range e.injected {
+		injected[string(f)] = c.Load()
+	}
+	return api.ChaosStats{Requests: e.requests.Load(), Injected: injected}
+}
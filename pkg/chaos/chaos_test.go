@@ -0,0 +1,167 @@
+package chaos
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fgiudici/headertrace/pkg/capture"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []string
+		wantErr bool
+	}{
+		{
+			name:  "global and per route rules",
+			rules: []string{"error=0.1", "reset=0.05", "/api:hang=0.5", "/api:truncate=0.5"},
+		},
+		{
+			name:    "unknown fault",
+			rules:   []string{"explode=0.1"},
+			wantErr: true,
+		},
+		{
+			name:    "missing probability",
+			rules:   []string{"error"},
+			wantErr: true,
+		},
+		{
+			name:    "missing fault for route",
+			rules:   []string{"/api"},
+			wantErr: true,
+		},
+		{
+			name:    "probability out of range",
+			rules:   []string{"error=1.5"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid probability",
+			rules:   []string{"error=often"},
+			wantErr: true,
+		},
+		{
+			name:    "probabilities add up to more than 1",
+			rules:   []string{"/api:error=0.6", "/api:reset=0.6"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPick(t *testing.T) {
+	e, err := New([]string{"error=1", "/api:hang=1", "/api/v2:reset=0", "/quiet:error=0"})
+	if err != nil {
+		t.Fatalf("New() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want Fault
+	}{
+		{path: "/", want: Error},
+		{path: "/foo", want: Error},
+		{path: "/api/users", want: Hang},
+		{path: "/api", want: Hang},
+		{path: "/apiv2", want: Error},
+		{path: "/api/v2/users", want: None},
+		{path: "/quiet", want: None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			if got := e.Pick(req); got != tt.want {
+				t.Fatalf("Pick(%s) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	stats := e.Stats()
+	if stats.Requests != int64(len(tests)) {
+		t.Fatalf("Stats() requests = %d, want %d", stats.Requests, len(tests))
+	}
+	if stats.Injected[string(Error)] != 3 || stats.Injected[string(Hang)] != 2 || stats.Injected[string(Reset)] != 0 {
+		t.Fatalf("Stats() injected = %v, want error:3 hang:2 reset:0", stats.Injected)
+	}
+}
+
+func TestInject(t *testing.T) {
+	body := []byte(`{"headers":{"Accept":"*/*"}}`)
+
+	tests := []struct {
+		fault   Fault
+		wantErr bool
+		wantLen int
+	}{
+		{fault: Reset, wantErr: true},
+		{fault: Truncate, wantErr: true},
+		{fault: BadLength, wantLen: len(body) / 2},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.fault), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Inject(w, r, tt.fault, http.StatusOK, body)
+			}))
+			defer ts.Close()
+
+			resp, err := http.Get(ts.URL)
+			if err == nil {
+				var got []byte
+				got, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err == nil && len(got) != tt.wantLen {
+					t.Fatalf("Inject(%s) body length = %d, want %d", tt.fault, len(got), tt.wantLen)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inject(%s) client error = %v, wantErr %v", tt.fault, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInjectResetCaptured(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Inject(w, r, Reset, http.StatusOK, nil)
+		}),
+		ConnContext: capture.ConnContext,
+	}
+	go func() { _ = srv.Serve(capture.NewListener(l, capture.DefaultLimit)) }()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	_, err = io.ReadAll(conn)
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("Inject(%s) client error = %v, want %v", Reset, err, syscall.ECONNRESET)
+	}
+}
@@ -0,0 +1,255 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// Device types of the clients.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceTV      = "tv"
+	DeviceConsole = "console"
+	DeviceBot     = "bot"
+	DeviceLibrary = "library"
+	DeviceUnknown = "unknown"
+)
+
+// Hints are the User-Agent client hints accepted by CheckHints, in their canonical case.
+var Hints = []string{
+	"Sec-CH-UA", "Sec-CH-UA-Arch", "Sec-CH-UA-Bitness", "Sec-CH-UA-Form-Factors",
+	"Sec-CH-UA-Full-Version", "Sec-CH-UA-Full-Version-List", "Sec-CH-UA-Mobile", "Sec-CH-UA-Model",
+	"Sec-CH-UA-Platform", "Sec-CH-UA-Platform-Version", "Sec-CH-UA-WoW64",
+}
+
+// AllHints stands for all the Hints in CheckHints.
+const AllHints = "all"
+
+// CheckHints validates the client hints the server asks for, returning the Accept-CH value.
+func CheckHints(names []string) (string, error) {
+	var hints []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, AllHints) {
+			return strings.Join(Hints, ", "), nil
+		}
+		i := slices.IndexFunc(Hints, func(hint string) bool { return strings.EqualFold(hint, name) })
+		if i < 0 {
+			return "", fmt.Errorf("invalid client hint '%s', expected '%s' or one of: %s", name, AllHints, strings.Join(Hints, ", "))
+		}
+		if !slices.Contains(hints, Hints[i]) {
+			hints = append(hints, Hints[i])
+		}
+	}
+	return strings.Join(hints, ", "), nil
+}
+
+// rule matches a product in the User-Agent: the first submatch, if any, is its version.
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// bots are the crawlers and link preview fetchers, checked before the browsers they may imitate.
+var bots = []rule{
+	{"Googlebot", regexp.MustCompile(`Googlebot(?:-\w+)?/([\d.]+)`)},
+	{"Bingbot", regexp.MustCompile(`bingbot/([\d.]+)`)},
+	{"Applebot", regexp.MustCompile(`Applebot/([\d.]+)`)},
+	{"DuckDuckBot", regexp.MustCompile(`DuckDuckBot(?:-\w+)?/([\d.]+)`)},
+	{"YandexBot", regexp.MustCompile(`YandexBot/([\d.]+)`)},
+	{"Baiduspider", regexp.MustCompile(`Baiduspider(?:-\w+)?/([\d.]+)`)},
+	{"Yahoo! Slurp", regexp.MustCompile(`Yahoo! Slurp`)},
+	{"GPTBot", regexp.MustCompile(`GPTBot/([\d.]+)`)},
+	{"ClaudeBot", regexp.MustCompile(`ClaudeBot/([\d.]+)`)},
+	{"CCBot", regexp.MustCompile(`CCBot/([\d.]+)`)},
+	{"AhrefsBot", regexp.MustCompile(`AhrefsBot/([\d.]+)`)},
+	{"SemrushBot", regexp.MustCompile(`SemrushBot(?:-\w+)?/([\d.~a-z]+)`)},
+	{"facebookexternalhit", regexp.MustCompile(`facebookexternalhit/([\d.]+)`)},
+	{"Twitterbot", regexp.MustCompile(`Twitterbot/([\d.]+)`)},
+	{"LinkedInBot", regexp.MustCompile(`LinkedInBot/([\d.]+)`)},
+	{"Slackbot", regexp.MustCompile(`Slackbot(?:-LinkExpanding)? ([\d.]+)`)},
+	{"Discordbot", regexp.MustCompile(`Discordbot/([\d.]+)`)},
+	{"TelegramBot", regexp.MustCompile(`TelegramBot`)},
+	{"WhatsApp", regexp.MustCompile(`WhatsApp/([\d.]+)`)},
+}
+
+// genericBot matches the User-Agents declaring themselves as bots.
+var genericBot = regexp.MustCompile(`(?i)bot\b|crawler|spider|scraper`)
+
+// libraries are the command line tools and HTTP client libraries.
+var libraries = []rule{
+	{"curl", regexp.MustCompile(`^curl/([\d.]+)`)},
+	{"Wget", regexp.MustCompile(`^Wget/([\d.]+)`)},
+	{"HTTPie", regexp.MustCompile(`^HTTPie/([\d.]+)`)},
+	{"PostmanRuntime", regexp.MustCompile(`^PostmanRuntime/([\d.]+)`)},
+	{"python-requests", regexp.MustCompile(`^python-requests/([\d.]+)`)},
+	{"python-httpx", regexp.MustCompile(`^python-httpx/([\d.]+)`)},
+	{"aiohttp", regexp.MustCompile(`aiohttp/([\d.]+)`)},
+	{"Python-urllib", regexp.MustCompile(`^Python-urllib/([\d.]+)`)},
+	{"Go-http-client", regexp.MustCompile(`^Go-http-client/([\d.]+)`)},
+	{"okhttp", regexp.MustCompile(`^okhttp/([\d.]+)`)},
+	{"axios", regexp.MustCompile(`^axios/([\d.]+)`)},
+	{"node-fetch", regexp.MustCompile(`^node-fetch(?:/([\d.]+))?`)},
+	{"undici", regexp.MustCompile(`^undici`)},
+	{"Java", regexp.MustCompile(`^Java(?:-http-client)?/([\d.]+)`)},
+	{"Apache-HttpClient", regexp.MustCompile(`^Apache-HttpClient/([\d.]+)`)},
+	{"libwww-perl", regexp.MustCompile(`^libwww-perl/([\d.]+)`)},
+}
+
+// browsers are checked in order, as most of them include the tokens of the ones they derive from.
+var browsers = []rule{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/([\d.]+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|OPiOS)/([\d.]+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/([\d.]+)`)},
+	{"Yandex Browser", regexp.MustCompile(`YaBrowser/([\d.]+)`)},
+	{"Vivaldi", regexp.MustCompile(`Vivaldi/([\d.]+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/([\d.]+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/([\d.]+)`)},
+	{"Safari", regexp.MustCompile(`Version/([\d.]+).*Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)([\d.]+)`)},
+}
+
+// windowsVersions maps the Windows NT versions to the marketing ones. Windows 11 reports
+// itself as Windows NT 10.0: only the Sec-CH-UA-Platform-Version hint tells them apart.
+var windowsVersions = map[string]string{
+	"10.0": "10", "6.3": "8.1", "6.2": "8", "6.1": "7", "6.0": "Vista", "5.2": "XP", "5.1": "XP",
+}
+
+var (
+	windowsPattern = regexp.MustCompile(`Windows NT ([\d.]+)`)
+	iosPattern     = regexp.MustCompile(`(?:iPhone|iPad|iPod).*? OS ([\d_]+)`)
+	androidPattern = regexp.MustCompile(`Android(?: ([\d.]+))?`)
+	macPattern     = regexp.MustCompile(`Mac OS X ([\d_.]+)`)
+	tizenPattern   = regexp.MustCompile(`Tizen ([\d.]+)`)
+	tvPattern      = regexp.MustCompile(`(?i)smart-?tv|\bTV\b|AppleTV|CrKey|Roku|BRAVIA|Web0S|HbbTV`)
+	consolePattern = regexp.MustCompile(`PlayStation|Xbox|Nintendo`)
+	tabletPattern  = regexp.MustCompile(`iPad|Tablet`)
+	mobilePattern  = regexp.MustCompile(`Mobi|iPhone|iPod|Windows Phone`)
+)
+
+// Parse classifies the User-Agent of the request and decodes its User-Agent client hints.
+// Returns nil if the request carries none of them.
+func Parse(h http.Header) *api.ClientInfo {
+	ua := h.Get("User-Agent")
+	hints := parseHints(h)
+	if ua == "" && hints == nil {
+		return nil
+	}
+
+	info := &api.ClientInfo{Device: DeviceUnknown, Hints: hints}
+	if ua == "" {
+		return info
+	}
+	if name, _, ok := match(ua, bots); ok {
+		info.Device, info.Bot = DeviceBot, &name
+		return info
+	}
+	if name, version, ok := match(ua, libraries); ok {
+		info.Device, info.Browser, info.BrowserVersion = DeviceLibrary, &name, version
+		return info
+	}
+	if genericBot.MatchString(ua) {
+		name := strings.SplitN(strings.Fields(ua)[0], "/", 2)[0]
+		info.Device, info.Bot = DeviceBot, &name
+		return info
+	}
+
+	if name, version, ok := match(ua, browsers); ok {
+		info.Browser, info.BrowserVersion = &name, version
+	}
+	info.Engine = engine(ua)
+	info.Os, info.OsVersion = operatingSystem(ua)
+
+	switch {
+	case consolePattern.MatchString(ua):
+		info.Device = DeviceConsole
+	case tvPattern.MatchString(ua):
+		info.Device = DeviceTV
+	case tabletPattern.MatchString(ua) || strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile"):
+		info.Device = DeviceTablet
+	case mobilePattern.MatchString(ua):
+		info.Device = DeviceMobile
+	case info.Os != nil && slices.Contains([]string{"Windows", "macOS", "Linux", "ChromeOS"}, *info.Os):
+		info.Device = DeviceDesktop
+	}
+	return info
+}
+
+// match returns the name and version of the first rule matching the User-Agent.
+func match(ua string, rules []rule) (string, *string, bool) {
+	for _, r := range rules {
+		m := r.pattern.FindStringSubmatch(ua)
+		if m == nil {
+			continue
+		}
+		if len(m) > 1 && m[1] != "" {
+			return r.name, &m[1], true
+		}
+		return r.name, nil, true
+	}
+	return "", nil, false
+}
+
+// engine returns the rendering engine: all the iOS browsers use WebKit.
+func engine(ua string) *string {
+	var name string
+	switch {
+	case iosPattern.MatchString(ua):
+		name = "WebKit"
+	case strings.Contains(ua, "Trident/") || strings.Contains(ua, "MSIE "):
+		name = "Trident"
+	case strings.Contains(ua, "Chrome/") || strings.Contains(ua, "Chromium/"):
+		name = "Blink"
+	case strings.Contains(ua, "Gecko/") && strings.Contains(ua, "Firefox/"):
+		name = "Gecko"
+	case strings.Contains(ua, "AppleWebKit/"):
+		name = "WebKit"
+	default:
+		return nil
+	}
+	return &name
+}
+
+// operatingSystem returns the name and version of the operating system.
+func operatingSystem(ua string) (*string, *string) {
+	name := func(s string) *string { return &s }
+	version := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		s = strings.ReplaceAll(s, "_", ".")
+		return &s
+	}
+	if m := windowsPattern.FindStringSubmatch(ua); m != nil {
+		if v, ok := windowsVersions[m[1]]; ok {
+			return name("Windows"), &v
+		}
+		return name("Windows"), version(m[1])
+	}
+	if m := iosPattern.FindStringSubmatch(ua); m != nil {
+		return name("iOS"), version(m[1])
+	}
+	if m := androidPattern.FindStringSubmatch(ua); m != nil {
+		return name("Android"), version(m[1])
+	}
+	if m := macPattern.FindStringSubmatch(ua); m != nil {
+		return name("macOS"), version(m[1])
+	}
+	if m := tizenPattern.FindStringSubmatch(ua); m != nil {
+		return name("Tizen"), version(m[1])
+	}
+	switch {
+	case strings.Contains(ua, "CrOS"):
+		return name("ChromeOS"), nil
+	case strings.Contains(ua, "Linux") || strings.Contains(ua, "X11"):
+		return name("Linux"), nil
+	}
+	return nil, nil
+}
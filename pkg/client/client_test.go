@@ -0,0 +1,223 @@
+package client
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+func TestCheckHints(t *testing.T) {
+	tests := []struct {
+		name    string
+		hints   []string
+		want    string
+		wantErr bool
+	}{
+		{name: "none", want: ""},
+		{name: "canonical case", hints: []string{"sec-ch-ua-model", "Sec-CH-UA-Platform-Version", "SEC-CH-UA-MODEL"}, want: "Sec-CH-UA-Model, Sec-CH-UA-Platform-Version"},
+		{name: "all", hints: []string{"Sec-CH-UA-Model", "all"}, want: strings.Join(Hints, ", ")},
+		{name: "unknown hint", hints: []string{"Sec-CH-Viewport-Width"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckHints(tt.hints)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckHints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("CheckHints() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{
+			name: "Chrome on Windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: "desktop Chrome 124.0.0.0 Blink Windows 10",
+		},
+		{
+			name: "Edge on Windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67",
+			want: "desktop Edge 124.0.2478.67 Blink Windows 10",
+		},
+		{
+			name: "Firefox on Linux",
+			ua:   "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want: "desktop Firefox 125.0 Gecko Linux ",
+		},
+		{
+			name: "Safari on macOS",
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+			want: "desktop Safari 17.4.1 WebKit macOS 10.15.7",
+		},
+		{
+			name: "Chrome on iPhone",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1",
+			want: "mobile Chrome 124.0.6367.88 WebKit iOS 17.4",
+		},
+		{
+			name: "Samsung Internet on Android phone",
+			ua:   "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+			want: "mobile Samsung Internet 24.0 Blink Android 14",
+		},
+		{
+			name: "Android tablet",
+			ua:   "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: "tablet Chrome 124.0.0.0 Blink Android 13",
+		},
+		{
+			name: "Internet Explorer 11",
+			ua:   "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			want: "desktop Internet Explorer 11.0 Trident Windows 7",
+		},
+		{
+			name: "console",
+			ua:   "Mozilla/5.0 (PlayStation; PlayStation 5/2.26) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0 Safari/605.1.15",
+			want: "console Safari 13.0 WebKit  ",
+		},
+		{
+			name: "smart TV",
+			ua:   "Mozilla/5.0 (SMART-TV; LINUX; Tizen 6.0) AppleWebKit/537.36 (KHTML, like Gecko) 76.0.3809.146/6.0 TV Safari/537.36",
+			want: "tv   WebKit Tizen 6.0",
+		},
+		{
+			name: "Googlebot imitating Chrome",
+			ua:   "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; Googlebot/2.1; +http://www.google.com/bot.html) Chrome/124.0.6367.91 Safari/537.36",
+			want: "bot Googlebot",
+		},
+		{
+			name: "generic bot",
+			ua:   "MyCrawler/1.0 (+https://example.com/crawler)",
+			want: "bot MyCrawler",
+		},
+		{
+			name: "curl",
+			ua:   "curl/8.5.0",
+			want: "library curl 8.5.0   ",
+		},
+		{
+			name: "unknown",
+			ua:   "Something",
+			want: "unknown     ",
+		},
+	}
+
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Parse(http.Header{"User-Agent": {tt.ua}})
+			if info == nil {
+				t.Fatalf("Parse() = nil")
+			}
+			got := info.Device + " " + str(info.Bot)
+			if info.Bot == nil {
+				got = strings.Join([]string{info.Device, str(info.Browser), str(info.BrowserVersion), str(info.Engine), str(info.Os), str(info.OsVersion)}, " ")
+			}
+			if got != tt.want {
+				t.Fatalf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if info := Parse(http.Header{"Accept": {"*/*"}}); info != nil {
+		t.Fatalf("Parse() = %+v, want nil without User-Agent and client hints", info)
+	}
+}
+
+func TestParseHints(t *testing.T) {
+	yes, no := true, false
+	s := func(v string) *string { return &v }
+	tests := []struct {
+		name    string
+		headers http.Header
+		want    *api.ClientHints
+	}{
+		{
+			name: "default hints",
+			headers: http.Header{
+				"Sec-Ch-Ua":          {`"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`},
+				"Sec-Ch-Ua-Mobile":   {"?0"},
+				"Sec-Ch-Ua-Platform": {`"Windows"`},
+			},
+			want: &api.ClientHints{
+				Brands:   &[]api.ClientBrand{{Brand: "Chromium", Version: "124"}, {Brand: "Google Chrome", Version: "124"}, {Brand: "Not-A.Brand", Version: "99"}},
+				Mobile:   &no,
+				Platform: s("Windows"),
+			},
+		},
+		{
+			name: "high entropy hints",
+			headers: http.Header{
+				"Sec-Ch-Ua-Full-Version-List":  {`"Not/A)Brand";v="8.0.0.0", "Chromium";v="126.0.6478.61"`},
+				"Sec-Ch-Ua-Platform-Version":   {`"15.0.0"`},
+				"Sec-Ch-Ua-Model":              {`"Pixel 8"`},
+				"Sec-Ch-Ua-Form-Factors":       {`"Mobile", "XR"`},
+				"Sec-Ch-Ua-Arch":               {`"arm"`},
+				"Sec-Ch-Ua-Bitness":            {`"64"`},
+				"Sec-Ch-Ua-Wow64":              {"?1"},
+				"Sec-Ch-Ua-Full-Version":       {`"126.0.6478.61"`},
+				"Sec-Ch-Ua-Mobile":             {"?1"},
+				"Sec-Ch-Ua-Platform":           {`"Android"`},
+				"Sec-Ch-Ua-Platform-Versionxx": {`"ignored"`},
+			},
+			want: &api.ClientHints{
+				Arch:            s("arm"),
+				Bitness:         s("64"),
+				FormFactors:     &[]string{"Mobile", "XR"},
+				FullVersion:     s("126.0.6478.61"),
+				FullVersionList: &[]api.ClientBrand{{Brand: "Not/A)Brand", Version: "8.0.0.0"}, {Brand: "Chromium", Version: "126.0.6478.61"}},
+				Mobile:          &yes,
+				Model:           s("Pixel 8"),
+				Platform:        s("Android"),
+				PlatformVersion: s("15.0.0"),
+				Wow64:           &yes,
+			},
+		},
+		{
+			name: "escaped string",
+			headers: http.Header{
+				"Sec-Ch-Ua-Model": {`"a \"quoted\" \\ model"`},
+			},
+			want: &api.ClientHints{Model: s(`a "quoted" \ model`)},
+		},
+		{
+			name: "malformed hints",
+			headers: http.Header{
+				"Sec-Ch-Ua":          {`"Chromium";v="124",`},
+				"Sec-Ch-Ua-Mobile":   {"1"},
+				"Sec-Ch-Ua-Platform": {`Windows`},
+				"Sec-Ch-Ua-Model":    {`"Pixel" 8`},
+			},
+			want: &api.ClientHints{Errors: &[]string{
+				"Sec-CH-UA: trailing comma",
+				"Sec-CH-UA-Mobile: expected a boolean, ?1 or ?0",
+				"Sec-CH-UA-Model: unexpected data after the string at byte 7",
+				"Sec-CH-UA-Platform: expected a string at byte 0",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseHints(tt.headers); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseHints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// parseHints decodes the User-Agent client hints, structured fields (RFC 8941) holding
+// strings, booleans or lists of strings. Returns nil if the request carries none of them.
+func parseHints(h http.Header) *api.ClientHints {
+	hints := &api.ClientHints{}
+	found := false
+	var errs []string
+	for _, name := range Hints {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		found = true
+		value := strings.Join(values, ",")
+
+		var err error
+		switch name {
+		case "Sec-CH-UA":
+			hints.Brands, err = brandList(value)
+		case "Sec-CH-UA-Full-Version-List":
+			hints.FullVersionList, err = brandList(value)
+		case "Sec-CH-UA-Form-Factors":
+			var items []item
+			if items, err = parseList(value); err == nil {
+				factors := make([]string, len(items))
+				for i, it := range items {
+					factors[i] = it.value
+				}
+				hints.FormFactors = &factors
+			}
+		case "Sec-CH-UA-Mobile":
+			hints.Mobile, err = parseBoolean(value)
+		case "Sec-CH-UA-WoW64":
+			hints.Wow64, err = parseBoolean(value)
+		case "Sec-CH-UA-Arch":
+			hints.Arch, err = parseString(value)
+		case "Sec-CH-UA-Bitness":
+			hints.Bitness, err = parseString(value)
+		case "Sec-CH-UA-Full-Version":
+			hints.FullVersion, err = parseString(value)
+		case "Sec-CH-UA-Model":
+			hints.Model, err = parseString(value)
+		case "Sec-CH-UA-Platform":
+			hints.Platform, err = parseString(value)
+		case "Sec-CH-UA-Platform-Version":
+			hints.PlatformVersion, err = parseString(value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if !found {
+		return nil
+	}
+	if len(errs) > 0 {
+		hints.Errors = &errs
+	}
+	return hints
+}
+
+// brandList parses a list of brands with their version parameter "v".
+func brandList(value string) (*[]api.ClientBrand, error) {
+	items, err := parseList(value)
+	if err != nil {
+		return nil, err
+	}
+	brands := make([]api.ClientBrand, len(items))
+	for i, it := range items {
+		brands[i] = api.ClientBrand{Brand: it.value, Version: it.params["v"]}
+	}
+	return &brands, nil
+}
+
+// item is a string item of a structured field list, with its string parameters.
+type item struct {
+	value  string
+	params map[string]string
+}
+
+// parseList parses a structured field list of strings.
+func parseList(value string) ([]item, error) {
+	p := &parser{s: value}
+	var items []item
+	p.skipSpaces()
+	for !p.done() {
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		it := item{value: s, params: map[string]string{}}
+		for p.peek() == ';' {
+			p.pos++
+			p.skipSpaces()
+			key := p.key()
+			if key == "" {
+				return nil, fmt.Errorf("invalid parameter key at byte %d", p.pos)
+			}
+			if p.peek() != '=' {
+				it.params[key] = ""
+				continue
+			}
+			p.pos++
+			if it.params[key], err = p.string(); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, it)
+		p.skipOWS()
+		if p.done() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("expected a comma at byte %d", p.pos)
+		}
+		p.pos++
+		p.skipOWS()
+		if p.done() {
+			return nil, fmt.Errorf("trailing comma")
+		}
+	}
+	return items, nil
+}
+
+// parseString parses a structured field string.
+func parseString(value string) (*string, error) {
+	p := &parser{s: strings.Trim(value, " ")}
+	s, err := p.string()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected data after the string at byte %d", p.pos)
+	}
+	return &s, nil
+}
+
+// parseBoolean parses a structured field boolean: ?1 or ?0.
+func parseBoolean(value string) (*bool, error) {
+	switch strings.Trim(value, " ") {
+	case "?1":
+		b := true
+		return &b, nil
+	case "?0":
+		b := false
+		return &b, nil
+	}
+	return nil, fmt.Errorf("expected a boolean, ?1 or ?0")
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) skipOWS() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// string parses a quoted string, with backslash escaping the quotes and backslashes.
+func (p *parser) string() (string, error) {
+	if p.peek() != '"' {
+		return "", fmt.Errorf("expected a string at byte %d", p.pos)
+	}
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if next := p.peek(); next == '"' || next == '\\' {
+				b.WriteByte(next)
+				p.pos++
+				continue
+			}
+			return "", fmt.Errorf("invalid escape at byte %d", p.pos-1)
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", fmt.Errorf("invalid character at byte %d", p.pos-1)
+		}
+		b.WriteByte(c)
+	}
+	return "", fmt.Errorf("unterminated string at byte %d", start)
+}
+
+// key parses a parameter key.
+func (p *parser) key() string {
+	start := p.pos
+	for !p.done() {
+		c := p.s[p.pos]
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("_-.*", c) >= 0 {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
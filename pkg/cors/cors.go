@@ -0,0 +1,244 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/logging"
+)
+
+// RegexPrefix marks an allowed origin rule as a regular expression (e.g. "~^https://.*\.example\.com$").
+const RegexPrefix = "~"
+
+// safelistedMethods are always allowed by browsers without being listed in Access-Control-Allow-Methods.
+var safelistedMethods = []string{"GET", "HEAD", "POST"}
+
+// safelistedHeaders are always allowed by browsers without being listed in Access-Control-Allow-Headers.
+var safelistedHeaders = []string{"accept", "accept-language", "content-language", "content-type"}
+
+// Config holds the CORS policy settings.
+type Config struct {
+	Origins     []string
+	Methods     []string
+	Headers     []string
+	Credentials bool
+	MaxAge      int
+}
+
+type originRule struct {
+	rule    string
+	pattern *regexp.Regexp
+}
+
+// Policy evaluates requests against a CORS configuration.
+type Policy struct {
+	rules       []originRule
+	methods     []string
+	headers     []string
+	credentials bool
+	maxAge      int
+}
+
+// New validates the CORS configuration and returns the corresponding Policy.
+// Origin rules are matched exactly, "*" matches any origin and rules starting with RegexPrefix are regular expressions.
+func New(cfg Config) (*Policy, error) {
+	p := &Policy{credentials: cfg.Credentials, maxAge: cfg.MaxAge}
+	if cfg.MaxAge < 0 {
+		return nil, fmt.Errorf("max age cannot be negative (%d)", cfg.MaxAge)
+	}
+
+	for _, o := range cfg.Origins {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		rule := originRule{rule: o}
+		if expr, ok := strings.CutPrefix(o, RegexPrefix); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid origin regex '%s': %w", expr, err)
+			}
+			rule.pattern = re
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	for _, m := range cfg.Methods {
+		if m = strings.ToUpper(strings.TrimSpace(m)); m != "" {
+			p.methods = append(p.methods, m)
+		}
+	}
+	for _, h := range cfg.Headers {
+		if h = strings.TrimSpace(h); h != "" {
+			p.headers = append(p.headers, h)
+		}
+	}
+	return p, nil
+}
+
+// IsPreflight checks if the request is a CORS preflight request.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Apply evaluates the request against the policy, sets the CORS response headers when the
+// request is allowed and returns the diagnostic information explaining the decision.
+func (p *Policy) Apply(w http.ResponseWriter, r *http.Request) *api.CorsInfo {
+	info := &api.CorsInfo{Preflight: IsPreflight(r), Reasons: []string{}}
+	// Responses depend on the Origin header: caches must not mix them up.
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		info.Reasons = append(info.Reasons, "request carries no Origin header, not a CORS request")
+		return info
+	}
+	info.Origin = &origin
+
+	rule, ok := p.matchOrigin(origin)
+	if !ok {
+		info.Reasons = append(info.Reasons, fmt.Sprintf("origin '%s' does not match any allowed origin rule %v", origin, p.ruleNames()))
+		logging.Debugf("CORS: rejected origin '%s'", origin)
+		return info
+	}
+	info.MatchedRule = &rule
+	info.Reasons = append(info.Reasons, fmt.Sprintf("origin '%s' matches rule '%s'", origin, rule))
+	info.Allowed = true
+
+	if info.Preflight {
+		p.checkPreflight(r, info)
+	}
+	if !info.Allowed {
+		logging.Debugf("CORS: rejected preflight from origin '%s': %v", origin, info.Reasons)
+		return info
+	}
+
+	allowOrigin := origin
+	if rule == "*" && !p.credentials {
+		allowOrigin = "*"
+	}
+	w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		if rule == "*" {
+			info.Reasons = append(info.Reasons, "credentials enabled: origin reflected instead of '*'")
+		}
+	}
+	if info.Preflight {
+		if len(p.methods) > 0 {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+		}
+		if allowHeaders := p.allowHeaders(info); allowHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if p.maxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(p.maxAge))
+		}
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+	}
+	logging.Debugf("CORS: allowed origin '%s' (rule '%s')", origin, rule)
+	return info
+}
+
+// checkPreflight verifies the method and headers requested in a preflight request.
+func (p *Policy) checkPreflight(r *http.Request, info *api.CorsInfo) {
+	method := r.Header.Get("Access-Control-Request-Method")
+	info.RequestMethod = &method
+	switch {
+	case slices.Contains(p.methods, strings.ToUpper(method)):
+		info.Reasons = append(info.Reasons, fmt.Sprintf("method '%s' is allowed", method))
+	case slices.Contains(safelistedMethods, method):
+		info.Reasons = append(info.Reasons, fmt.Sprintf("method '%s' is CORS-safelisted", method))
+	default:
+		info.Allowed = false
+		info.Reasons = append(info.Reasons, fmt.Sprintf("method '%s' is not in the allowed methods %v", method, p.methods))
+	}
+
+	requested := parseList(r.Header.Values("Access-Control-Request-Headers"))
+	if len(requested) == 0 {
+		return
+	}
+	info.RequestHeaders = &requested
+	for _, h := range requested {
+		switch {
+		case containsFold(p.headers, h):
+			info.Reasons = append(info.Reasons, fmt.Sprintf("header '%s' is allowed", h))
+		case slices.Contains(p.headers, "*") && p.credentials:
+			info.Reasons = append(info.Reasons, fmt.Sprintf("header '%s' is allowed by wildcard, reflected as credentials are enabled", h))
+		case slices.Contains(p.headers, "*"):
+			info.Reasons = append(info.Reasons, fmt.Sprintf("header '%s' is allowed by wildcard", h))
+		case slices.Contains(safelistedHeaders, strings.ToLower(h)):
+			info.Reasons = append(info.Reasons, fmt.Sprintf("header '%s' is CORS-safelisted", h))
+		default:
+			info.Allowed = false
+			info.Reasons = append(info.Reasons, fmt.Sprintf("header '%s' is not in the allowed headers %v", h, p.headers))
+		}
+	}
+}
+
+// allowHeaders returns the Access-Control-Allow-Headers value for a preflight request: the
+// configured headers followed by the requested ones, all of them allowed by checkPreflight.
+// Browsers only skip the check for the safelisted headers with safelisted values, and the
+// wildcard neither covers Authorization nor can be used with credentials, so the requested
+// headers are always listed.
+func (p *Policy) allowHeaders(info *api.CorsInfo) string {
+	allowed := slices.Clone(p.headers)
+	if p.credentials {
+		allowed = slices.DeleteFunc(allowed, func(h string) bool { return h == "*" })
+	}
+	if info.RequestHeaders != nil {
+		for _, h := range *info.RequestHeaders {
+			if !containsFold(allowed, h) {
+				allowed = append(allowed, h)
+			}
+		}
+	}
+	return strings.Join(allowed, ", ")
+}
+
+func (p *Policy) matchOrigin(origin string) (string, bool) {
+	for _, r := range p.rules {
+		switch {
+		case r.pattern != nil:
+			if r.pattern.MatchString(origin) {
+				return r.rule, true
+			}
+		case r.rule == "*" || strings.EqualFold(r.rule, origin):
+			return r.rule, true
+		}
+	}
+	return "", false
+}
+
+func (p *Policy) ruleNames() []string {
+	names := make([]string, len(p.rules))
+	for i, r := range p.rules {
+		names[i] = r.rule
+	}
+	return names
+}
+
+// parseList splits comma separated header values in a list of trimmed, lowercase tokens.
+func parseList(values []string) []string {
+	list := []string{}
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(item string) bool { return strings.EqualFold(item, s) })
+}
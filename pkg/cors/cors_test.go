@@ -0,0 +1,238 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "exact and regex origins",
+			cfg:  Config{Origins: []string{"https://app.example.com", `~^https://.*\.example\.com$`}},
+		},
+		{
+			name:    "invalid regex",
+			cfg:     Config{Origins: []string{"~^https://(.*$"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative max age",
+			cfg:     Config{Origins: []string{"*"}, MaxAge: -1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		method      string
+		headers     http.Header
+		wantAllowed bool
+		wantRule    string
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "no origin",
+			cfg:         Config{Origins: []string{"*"}},
+			method:      http.MethodGet,
+			headers:     http.Header{},
+			wantAllowed: false,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "exact origin",
+			cfg:         Config{Origins: []string{"https://app.example.com"}},
+			method:      http.MethodGet,
+			headers:     http.Header{"Origin": {"https://app.example.com"}},
+			wantAllowed: true,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"},
+		},
+		{
+			name:        "regex origin",
+			cfg:         Config{Origins: []string{"https://other.com", `~^https://.*\.example\.com$`}},
+			method:      http.MethodGet,
+			headers:     http.Header{"Origin": {"https://api.example.com"}},
+			wantAllowed: true,
+			wantRule:    `~^https://.*\.example\.com$`,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "https://api.example.com"},
+		},
+		{
+			name:        "origin not allowed",
+			cfg:         Config{Origins: []string{"https://app.example.com"}},
+			method:      http.MethodGet,
+			headers:     http.Header{"Origin": {"https://evil.com"}},
+			wantAllowed: false,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "wildcard origin",
+			cfg:         Config{Origins: []string{"*"}},
+			method:      http.MethodGet,
+			headers:     http.Header{"Origin": {"https://app.example.com"}},
+			wantAllowed: true,
+			wantRule:    "*",
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:        "wildcard origin with credentials reflects origin",
+			cfg:         Config{Origins: []string{"*"}, Credentials: true},
+			method:      http.MethodGet,
+			headers:     http.Header{"Origin": {"https://app.example.com"}},
+			wantAllowed: true,
+			wantRule:    "*",
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:   "preflight allowed",
+			cfg:    Config{Origins: []string{"https://app.example.com"}, Methods: []string{"GET", "PUT"}, Headers: []string{"X-Request-Id"}, MaxAge: 600},
+			method: http.MethodOptions,
+			headers: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"PUT"},
+				"Access-Control-Request-Headers": {"x-request-id, content-type"},
+			},
+			wantAllowed: true,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "X-Request-Id, content-type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:   "preflight method not allowed",
+			cfg:    Config{Origins: []string{"https://app.example.com"}, Methods: []string{"GET"}},
+			method: http.MethodOptions,
+			headers: http.Header{
+				"Origin":                        {"https://app.example.com"},
+				"Access-Control-Request-Method": {"DELETE"},
+			},
+			wantAllowed: false,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight header not allowed",
+			cfg:    Config{Origins: []string{"https://app.example.com"}, Methods: []string{"GET"}},
+			method: http.MethodOptions,
+			headers: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"x-secret"},
+			},
+			wantAllowed: false,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight safelisted header listed in the allowed headers",
+			cfg:    Config{Origins: []string{"https://app.example.com"}, Methods: []string{"GET"}},
+			method: http.MethodOptions,
+			headers: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"content-type"},
+			},
+			wantAllowed: true,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{"Access-Control-Allow-Headers": "content-type"},
+		},
+		{
+			name:   "preflight wildcard headers list Authorization",
+			cfg:    Config{Origins: []string{"https://app.example.com"}, Headers: []string{"*"}},
+			method: http.MethodOptions,
+			headers: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"authorization"},
+			},
+			wantAllowed: true,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{"Access-Control-Allow-Headers": "*, authorization"},
+		},
+		{
+			name:   "preflight wildcard headers with credentials reflects requested headers",
+			cfg:    Config{Origins: []string{"https://app.example.com"}, Headers: []string{"*"}, Credentials: true},
+			method: http.MethodOptions,
+			headers: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"content-type"},
+			},
+			wantAllowed: true,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{"Access-Control-Allow-Headers": "content-type"},
+		},
+		{
+			name:   "preflight wildcard headers with credentials allows custom headers",
+			cfg:    Config{Origins: []string{"https://app.example.com"}, Headers: []string{"*"}, Credentials: true},
+			method: http.MethodOptions,
+			headers: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"x-request-id, authorization"},
+			},
+			wantAllowed: true,
+			wantRule:    "https://app.example.com",
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Headers":     "x-request-id, authorization",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("New() unexpected error = %v", err)
+			}
+			req := httptest.NewRequest(tt.method, "http://example.com/", nil)
+			req.Header = tt.headers
+			rec := httptest.NewRecorder()
+
+			info := p.Apply(rec, req)
+			if info.Allowed != tt.wantAllowed {
+				t.Fatalf("Apply() allowed = %v, want %v (reasons: %v)", info.Allowed, tt.wantAllowed, info.Reasons)
+			}
+			if len(info.Reasons) == 0 {
+				t.Fatalf("Apply() returned no reasons")
+			}
+			rule := ""
+			if info.MatchedRule != nil {
+				rule = *info.MatchedRule
+			}
+			if rule != tt.wantRule {
+				t.Fatalf("Apply() matched rule = %q, want %q", rule, tt.wantRule)
+			}
+			for key, want := range tt.wantHeaders {
+				if got := rec.Header().Get(key); got != want {
+					t.Fatalf("Apply() header %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
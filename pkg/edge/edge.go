@@ -0,0 +1,269 @@
+package edge
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// Edge providers detected from the headers they add to the requests.
+const (
+	Cloudflare     = "cloudflare"
+	Fastly         = "fastly"
+	Akamai         = "akamai"
+	CloudFront     = "cloudfront"
+	AzureFrontDoor = "azure-front-door"
+	GoogleCloudLB  = "google-cloud-lb"
+	Vercel         = "vercel"
+	Netlify        = "netlify"
+)
+
+// provider describes the header signatures of an edge platform.
+type provider struct {
+	name string
+	// prefixes of the header names set by the provider, in lower case
+	prefixes []string
+	// token of the provider in the CDN-Loop or Via headers, in lower case
+	token string
+	// headers holding the client address, in order of preference
+	clientIP []string
+	// header holding the request ID
+	requestID string
+	// headers holding the country code of the client
+	country []string
+	// pop returns the point of presence or region serving the request
+	pop func(h http.Header) string
+}
+
+var (
+	fastlyPoP   = regexp.MustCompile(`cache-[a-z0-9]+-([A-Z]{3})\b`)
+	edgescapeCC = regexp.MustCompile(`(?:^|,)\s*country_code=([A-Za-z]{2})\b`)
+)
+
+// providers are the edge platforms, in the order they are reported.
+var providers = []provider{
+	{
+		name:      Cloudflare,
+		prefixes:  []string{"cf-"},
+		token:     "cloudflare",
+		clientIP:  []string{"Cf-Connecting-Ip", "Cf-Connecting-Ipv6"},
+		requestID: "Cf-Ray",
+		country:   []string{"Cf-Ipcountry"},
+		pop: func(h http.Header) string {
+			// Cf-Ray: 9cbdc3515d22baf3-MXP
+			if _, pop, ok := strings.Cut(h.Get("Cf-Ray"), "-"); ok {
+				return pop
+			}
+			return ""
+		},
+	},
+	{
+		name:     Fastly,
+		prefixes: []string{"fastly-"},
+		token:    "fastly",
+		clientIP: []string{"Fastly-Client-Ip"},
+		pop: func(h http.Header) string {
+			// Fastly-FF: 7lBhKmvVf0hBThaXKpoQdJIGbYnj3I0ytYmkT5PtfKw=!MXP!cache-mxp6921-MXP
+			if m := fastlyPoP.FindStringSubmatch(h.Get("Fastly-Ff")); m != nil {
+				return m[1]
+			}
+			return ""
+		},
+	},
+	{
+		name:      Akamai,
+		prefixes:  []string{"akamai-", "x-akamai-"},
+		token:     "akamai",
+		clientIP:  []string{"True-Client-Ip"},
+		requestID: "Akamai-Grn",
+		country:   []string{"X-Akamai-Edgescape"},
+	},
+	{
+		name:      CloudFront,
+		prefixes:  []string{"cloudfront-", "x-amz-cf-"},
+		token:     "cloudfront",
+		clientIP:  []string{"Cloudfront-Viewer-Address"},
+		requestID: "X-Amz-Cf-Id",
+		country:   []string{"Cloudfront-Viewer-Country"},
+	},
+	{
+		name:      AzureFrontDoor,
+		prefixes:  []string{"x-azure-", "x-fd-"},
+		clientIP:  []string{"X-Azure-Clientip", "X-Azure-Socketip"},
+		requestID: "X-Azure-Ref",
+	},
+	{
+		name:      GoogleCloudLB,
+		token:     "google",
+		clientIP:  []string{"X-Forwarded-For"},
+		requestID: "X-Cloud-Trace-Context",
+	},
+	{
+		name:      Vercel,
+		prefixes:  []string{"x-vercel-"},
+		clientIP:  []string{"X-Vercel-Forwarded-For"},
+		requestID: "X-Vercel-Id",
+		country:   []string{"X-Vercel-Ip-Country"},
+		pop: func(h http.Header) string {
+			// X-Vercel-Id: fra1::iad1::8vzbk-1700000000000-4d5f2e1a7c3b
+			if id := h.Get("X-Vercel-Id"); strings.Contains(id, "::") {
+				pop, _, _ := strings.Cut(id, "::")
+				return pop
+			}
+			return ""
+		},
+	},
+	{
+		name:      Netlify,
+		prefixes:  []string{"x-nf-"},
+		token:     "netlify",
+		clientIP:  []string{"X-Nf-Client-Connection-Ip"},
+		requestID: "X-Nf-Request-Id",
+		country:   []string{"X-Country"},
+	},
+}
+
+// IsHeader checks if a header is set by one of the edge providers, from its name prefix
+// (e.g. Cf-Ray, Fastly-Client-Ip, X-Amz-Cf-Id, X-Vercel-Id).
+func IsHeader(header string) bool {
+	lower := strings.ToLower(header)
+	for _, p := range providers {
+		for _, prefix := range p.prefixes {
+			if strings.HasPrefix(lower, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Detect returns the edge providers the request went through, recognized by the prefixes
+// of the header names they set, or by their tokens in the CDN-Loop and Via headers. Each
+// of them is reported with the headers that identified it, its point of presence, the client
+// address and country it reports and the ID of the request. Returns nil if none is found.
+func Detect(h http.Header) []api.EdgeInfo {
+	loop := tokens(h.Values("Cdn-Loop"), false)
+	via := tokens(h.Values("Via"), true)
+
+	var edges []api.EdgeInfo
+	for _, p := range providers {
+		var signatures []string
+		for name := range h {
+			lower := strings.ToLower(name)
+			if slices.ContainsFunc(p.prefixes, func(prefix string) bool { return strings.HasPrefix(lower, prefix) }) {
+				signatures = append(signatures, name)
+			}
+		}
+		slices.Sort(signatures)
+		if p.token != "" && slices.Contains(loop, p.token) {
+			signatures = append(signatures, "Cdn-Loop")
+		}
+		if p.token != "" && slices.Contains(via, p.token) {
+			signatures = append(signatures, "Via")
+		}
+		if len(signatures) == 0 {
+			continue
+		}
+
+		info := api.EdgeInfo{Provider: p.name, Signatures: signatures}
+		for _, name := range p.clientIP {
+			if address := clientAddress(p.name, h.Values(name)); address != "" {
+				info.ClientIp, info.ClientIpHeader = &address, &name
+				break
+			}
+		}
+		if p.requestID != "" {
+			if id := requestID(p.name, h.Get(p.requestID)); id != "" {
+				info.RequestId = &id
+			}
+		}
+		for _, name := range p.country {
+			if country := countryCode(p.name, h.Get(name)); country != "" {
+				info.Country = &country
+				break
+			}
+		}
+		if p.pop != nil {
+			if pop := p.pop(h); pop != "" {
+				info.Pop = &pop
+			}
+		}
+		edges = append(edges, info)
+	}
+	return edges
+}
+
+// clientAddress returns the client address of a header value set by the provider.
+func clientAddress(provider string, values []string) string {
+	value := strings.TrimSpace(strings.Join(values, ","))
+	switch provider {
+	case CloudFront:
+		// CloudFront-Viewer-Address: 198.51.100.10:46532, the port follows IPv6 addresses too
+		if i := strings.LastIndexByte(value, ':'); i > 0 {
+			return value[:i]
+		}
+	case GoogleCloudLB:
+		// X-Forwarded-For: <client>, <load balancer>, appended to the client values
+		nodes := strings.Split(value, ",")
+		if len(nodes) < 2 {
+			return ""
+		}
+		return strings.TrimSpace(nodes[len(nodes)-2])
+	}
+	return value
+}
+
+// requestID returns the request ID of a header value set by the provider.
+func requestID(provider, value string) string {
+	value = strings.TrimSpace(value)
+	if provider == GoogleCloudLB {
+		// X-Cloud-Trace-Context: TRACE_ID/SPAN_ID;o=OPTIONS
+		value, _, _ = strings.Cut(value, "/")
+	}
+	return value
+}
+
+// countryCode returns the country code of a header value set by the provider.
+func countryCode(provider, value string) string {
+	value = strings.TrimSpace(value)
+	if provider == Akamai {
+		// X-Akamai-Edgescape: georegion=263,country_code=US,region_code=CA,city=SANJOSE,...
+		m := edgescapeCC.FindStringSubmatch(value)
+		if m == nil {
+			return ""
+		}
+		value = m[1]
+	}
+	return strings.ToUpper(value)
+}
+
+// tokens returns the lower case tokens of the CDN-Loop header, e.g. "cloudflare; loops=1",
+// or the pseudonyms of the Via header, e.g. "1.1 google" or "1.1 abc.cloudfront.net (CloudFront)",
+// keeping the last label but the top-level domain of the host names.
+func tokens(values []string, via bool) []string {
+	var list []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			element, _, _ = strings.Cut(strings.TrimSpace(element), ";")
+			fields := strings.Fields(strings.ToLower(element))
+			if via {
+				if len(fields) < 2 {
+					continue
+				}
+				fields = fields[1:2]
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			token := fields[0]
+			if labels := strings.Split(token, "."); len(labels) > 1 {
+				token = labels[len(labels)-2]
+			}
+			list = append(list, token)
+		}
+	}
+	return list
+}
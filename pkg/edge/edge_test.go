@@ -0,0 +1,164 @@
+package edge
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+func TestDetect(t *testing.T) {
+	s := func(v string) *string { return &v }
+	tests := []struct {
+		name    string
+		headers http.Header
+		want    []api.EdgeInfo
+	}{
+		{
+			name:    "no edge",
+			headers: http.Header{"Via": {"1.1 varnish"}, "X-Forwarded-For": {"203.0.113.9"}},
+		},
+		{
+			name: "Cloudflare",
+			headers: http.Header{
+				"Cdn-Loop":         {"cloudflare; loops=1"},
+				"Cf-Connecting-Ip": {"203.0.113.9"},
+				"Cf-Ipcountry":     {"it"},
+				"Cf-Ray":           {"9cbdc3515d22baf3-MXP"},
+			},
+			want: []api.EdgeInfo{{
+				Provider: Cloudflare, Signatures: []string{"Cf-Connecting-Ip", "Cf-Ipcountry", "Cf-Ray", "Cdn-Loop"},
+				ClientIp: s("203.0.113.9"), ClientIpHeader: s("Cf-Connecting-Ip"), Country: s("IT"), Pop: s("MXP"), RequestId: s("9cbdc3515d22baf3-MXP"),
+			}},
+		},
+		{
+			name: "Fastly",
+			headers: http.Header{
+				"Fastly-Client-Ip": {"203.0.113.9"},
+				"Fastly-Ff":        {"7lBhKmvVf0hBThaXKpoQdJIGbYnj3I0ytYmkT5PtfKw=!FRA!cache-fra19129-FRA, 7lBhKmvVf0hBThaXKpoQdJIGbYnj3I0ytYmkT5PtfKw=!FRA!cache-fra19155-FRA"},
+			},
+			want: []api.EdgeInfo{{
+				Provider: Fastly, Signatures: []string{"Fastly-Client-Ip", "Fastly-Ff"},
+				ClientIp: s("203.0.113.9"), ClientIpHeader: s("Fastly-Client-Ip"), Pop: s("FRA"),
+			}},
+		},
+		{
+			name: "Akamai",
+			headers: http.Header{
+				"Akamai-Origin-Hop":  {"2"},
+				"Akamai-Grn":         {"0.4e1f1402.1700000000.2a3b4c"},
+				"Cdn-Loop":           {"akamai;v=1.0;c=1"},
+				"True-Client-Ip":     {"203.0.113.9"},
+				"X-Akamai-Edgescape": {"georegion=263,country_code=US,region_code=CA,city=SANJOSE"},
+			},
+			want: []api.EdgeInfo{{
+				Provider: Akamai, Signatures: []string{"Akamai-Grn", "Akamai-Origin-Hop", "X-Akamai-Edgescape", "Cdn-Loop"},
+				ClientIp: s("203.0.113.9"), ClientIpHeader: s("True-Client-Ip"), Country: s("US"), RequestId: s("0.4e1f1402.1700000000.2a3b4c"),
+			}},
+		},
+		{
+			name: "CloudFront",
+			headers: http.Header{
+				"Cloudfront-Viewer-Address": {"2001:db8::1:46532"},
+				"Cloudfront-Viewer-Country": {"DE"},
+				"Via":                       {"2.0 f3e8a9c1b2d4e5f6a7b8c9d0e1f2a3b4.cloudfront.net (CloudFront)"},
+				"X-Amz-Cf-Id":               {"ZJx1C3DHtFQTa3nUT8JgQm6nHBkYu9Ymq3bC8HCdyb0T7Ym6NeHuYA=="},
+			},
+			want: []api.EdgeInfo{{
+				Provider: CloudFront, Signatures: []string{"Cloudfront-Viewer-Address", "Cloudfront-Viewer-Country", "X-Amz-Cf-Id", "Via"},
+				ClientIp: s("2001:db8::1"), ClientIpHeader: s("Cloudfront-Viewer-Address"), Country: s("DE"), RequestId: s("ZJx1C3DHtFQTa3nUT8JgQm6nHBkYu9Ymq3bC8HCdyb0T7Ym6NeHuYA=="),
+			}},
+		},
+		{
+			name: "Azure Front Door",
+			headers: http.Header{
+				"X-Azure-Fdid":     {"a0a0a0a0-bbbb-cccc-dddd-e1e1e1e1e1e1"},
+				"X-Azure-Ref":      {"0zxV+XAAAAABKMMOjBv2NT4TY6SQVjC0zV1NURURHRTA2MTkANjA5ZjVhMmItNTMzYy00MjZlLWFlZjQtOTk4NTM4Y2Q4ZjM3"},
+				"X-Azure-Socketip": {"203.0.113.9"},
+			},
+			want: []api.EdgeInfo{{
+				Provider: AzureFrontDoor, Signatures: []string{"X-Azure-Fdid", "X-Azure-Ref", "X-Azure-Socketip"},
+				ClientIp: s("203.0.113.9"), ClientIpHeader: s("X-Azure-Socketip"), RequestId: s("0zxV+XAAAAABKMMOjBv2NT4TY6SQVjC0zV1NURURHRTA2MTkANjA5ZjVhMmItNTMzYy00MjZlLWFlZjQtOTk4NTM4Y2Q4ZjM3"),
+			}},
+		},
+		{
+			name: "Google Cloud load balancer",
+			headers: http.Header{
+				"Via":                   {"1.1 google"},
+				"X-Cloud-Trace-Context": {"105445aa7843bc8bf206b12000100000/1;o=1"},
+				"X-Forwarded-For":       {"198.51.100.7, 203.0.113.9, 34.120.0.10"},
+			},
+			want: []api.EdgeInfo{{
+				Provider: GoogleCloudLB, Signatures: []string{"Via"},
+				ClientIp: s("203.0.113.9"), ClientIpHeader: s("X-Forwarded-For"), RequestId: s("105445aa7843bc8bf206b12000100000"),
+			}},
+		},
+		{
+			name: "Cloudflare in front of Vercel",
+			headers: http.Header{
+				"Cf-Connecting-Ip":       {"203.0.113.9"},
+				"Cf-Ray":                 {"9cbdc3515d22baf3-FRA"},
+				"X-Vercel-Forwarded-For": {"172.70.1.1"},
+				"X-Vercel-Id":            {"fra1::iad1::8vzbk-1700000000000-4d5f2e1a7c3b"},
+				"X-Vercel-Ip-Country":    {"DE"},
+			},
+			want: []api.EdgeInfo{
+				{
+					Provider: Cloudflare, Signatures: []string{"Cf-Connecting-Ip", "Cf-Ray"},
+					ClientIp: s("203.0.113.9"), ClientIpHeader: s("Cf-Connecting-Ip"), Pop: s("FRA"), RequestId: s("9cbdc3515d22baf3-FRA"),
+				},
+				{
+					Provider: Vercel, Signatures: []string{"X-Vercel-Forwarded-For", "X-Vercel-Id", "X-Vercel-Ip-Country"},
+					ClientIp: s("172.70.1.1"), ClientIpHeader: s("X-Vercel-Forwarded-For"), Country: s("DE"), Pop: s("fra1"), RequestId: s("fra1::iad1::8vzbk-1700000000000-4d5f2e1a7c3b"),
+				},
+			},
+		},
+		{
+			name: "Netlify",
+			headers: http.Header{
+				"X-Country":                 {"GB"},
+				"X-Nf-Client-Connection-Ip": {"203.0.113.9"},
+				"X-Nf-Request-Id":           {"01HF2ZKQ8X0R5J3W2V6Y7T9B1C"},
+			},
+			want: []api.EdgeInfo{{
+				Provider: Netlify, Signatures: []string{"X-Nf-Client-Connection-Ip", "X-Nf-Request-Id"},
+				ClientIp: s("203.0.113.9"), ClientIpHeader: s("X-Nf-Client-Connection-Ip"), Country: s("GB"), RequestId: s("01HF2ZKQ8X0R5J3W2V6Y7T9B1C"),
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.headers); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Detect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "Cf-Ray", want: true},
+		{header: "fastly-client-ip", want: true},
+		{header: "X-Akamai-Edgescape", want: true},
+		{header: "CloudFront-Viewer-Country", want: true},
+		{header: "X-Amz-Cf-Id", want: true},
+		{header: "X-Azure-Ref", want: true},
+		{header: "X-Vercel-Id", want: true},
+		{header: "X-Nf-Request-Id", want: true},
+		{header: "X-Forwarded-For", want: false},
+		{header: "Cache-Control", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := IsHeader(tt.header); got != tt.want {
+				t.Fatalf("IsHeader(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
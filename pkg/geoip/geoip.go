@@ -0,0 +1,107 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/logging"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// record holds the fields of the City, Country, ASN and ISP databases of MaxMind and DB-IP.
+type record struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	ASOrg        string `maxminddb:"autonomous_system_organization"`
+	Organization string `maxminddb:"organization"`
+}
+
+// DB looks up the client addresses in MaxMind DB (.mmdb) files. A nil DB looks up nothing.
+type DB struct {
+	readers []*maxminddb.Reader
+}
+
+// Open opens the database files: geolocation databases, such as GeoLite2-City or
+// dbip-city-lite, and ASN or ISP ones, such as GeoLite2-ASN or dbip-asn-lite.
+func Open(paths []string) (*DB, error) {
+	db := &DB{}
+	for _, path := range paths {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("invalid database '%s': %v", path, err)
+		}
+		built := time.Unix(int64(reader.Metadata.BuildEpoch), 0).UTC().Format(time.DateOnly)
+		logging.Debugf("GeoIP database '%s': %s, built on %s", path, reader.Metadata.DatabaseType, built)
+		db.readers = append(db.readers, reader)
+	}
+	return db, nil
+}
+
+// Close closes the database files.
+func (db *DB) Close() error {
+	if db == nil {
+		return nil
+	}
+	var errs []error
+	for _, reader := range db.readers {
+		errs = append(errs, reader.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Lookup returns the country, city, autonomous system and organization of the address, merging
+// the records of all the databases: the first database holding a field provides it. Returns nil
+// if the address is not valid, or is found in none of the databases.
+func (db *DB) Lookup(address string) *api.GeoInfo {
+	if db == nil || len(db.readers) == 0 {
+		return nil
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	info := &api.GeoInfo{Address: addr.String()}
+	found := false
+	set := func(field **string, value string) {
+		if *field == nil && value != "" {
+			*field = &value
+		}
+	}
+	for _, reader := range db.readers {
+		var rec record
+		_, ok, err := reader.LookupNetwork(addr.AsSlice(), &rec)
+		if err != nil {
+			logging.Debugf("GeoIP lookup of %s in %s: %v", addr, reader.Metadata.DatabaseType, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		set(&info.Country, rec.Country.ISOCode)
+		set(&info.CountryName, rec.Country.Names["en"])
+		set(&info.City, rec.City.Names["en"])
+		if info.Asn == nil && rec.ASN != 0 {
+			asn := int(rec.ASN)
+			info.Asn = &asn
+		}
+		set(&info.Organization, rec.ASOrg)
+		set(&info.Organization, rec.Organization)
+	}
+	if !found {
+		return nil
+	}
+	return info
+}
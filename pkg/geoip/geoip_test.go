@@ -0,0 +1,226 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// encode serializes a value in the MaxMind DB data section format.
+func encode(v interface{}) []byte {
+	control := func(typ byte, size int, payload []byte) []byte {
+		var b []byte
+		head := byte(size)
+		if size >= 29 {
+			head = 29
+		}
+		if typ <= 7 {
+			b = append(b, typ<<5|head)
+		} else {
+			b = append(b, head, typ-7)
+		}
+		if size >= 29 {
+			b = append(b, byte(size-29))
+		}
+		return append(b, payload...)
+	}
+	unsigned := func(typ byte, n uint64) []byte {
+		var payload []byte
+		for ; n > 0; n >>= 8 {
+			payload = append([]byte{byte(n)}, payload...)
+		}
+		return control(typ, len(payload), payload)
+	}
+
+	switch v := v.(type) {
+	case string:
+		return control(2, len(v), []byte(v))
+	case uint16:
+		return unsigned(5, uint64(v))
+	case uint32:
+		return unsigned(6, uint64(v))
+	case uint64:
+		return unsigned(9, v)
+	case []interface{}:
+		b := control(11, len(v), nil)
+		for _, item := range v {
+			b = append(b, encode(item)...)
+		}
+		return b
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		b := control(7, len(v), nil)
+		for _, key := range keys {
+			b = append(b, encode(key)...)
+			b = append(b, encode(v[key])...)
+		}
+		return b
+	}
+	panic("unsupported type")
+}
+
+// writeDB writes an IPv4 MaxMind DB with 24 bit records, mapping the networks to their records.
+func writeDB(t *testing.T, dbType string, networks map[string]map[string]interface{}) string {
+	t.Helper()
+	const (
+		empty = -1
+		data  = -2
+	)
+	type node struct{ children, offsets [2]int }
+	nodes := []node{{children: [2]int{empty, empty}}}
+	var section []byte
+	for cidr, rec := range networks {
+		prefix := netip.MustParsePrefix(cidr)
+		ip := prefix.Addr().As4()
+		n := 0
+		for bit := 0; bit < prefix.Bits(); bit++ {
+			side := int(ip[bit/8]>>(7-bit%8)) & 1
+			if bit == prefix.Bits()-1 {
+				nodes[n].children[side], nodes[n].offsets[side] = data, len(section)
+				break
+			}
+			if nodes[n].children[side] == empty {
+				nodes = append(nodes, node{children: [2]int{empty, empty}})
+				nodes[n].children[side] = len(nodes) - 1
+			}
+			n = nodes[n].children[side]
+		}
+		section = append(section, encode(rec)...)
+	}
+
+	var db bytes.Buffer
+	for _, n := range nodes {
+		for side, child := range n.children {
+			value := child
+			switch child {
+			case empty:
+				value = len(nodes)
+			case data:
+				value = len(nodes) + 16 + n.offsets[side]
+			}
+			db.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	db.Write(make([]byte, 16))
+	db.Write(section)
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+	db.Write(encode(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": "headertrace test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+	}))
+
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	if err := os.WriteFile(path, db.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	city := writeDB(t, "GeoLite2-City", map[string]map[string]interface{}{
+		"81.2.69.0/24": {
+			"city":    map[string]interface{}{"names": map[string]interface{}{"en": "London", "de": "London"}},
+			"country": map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom"}},
+		},
+		"89.160.20.0/25": {
+			"country": map[string]interface{}{"iso_code": "SE", "names": map[string]interface{}{"en": "Sweden"}},
+		},
+	})
+	asn := writeDB(t, "GeoLite2-ASN", map[string]map[string]interface{}{
+		"81.2.69.0/23":   {"autonomous_system_number": uint32(20712), "autonomous_system_organization": "Andrews & Arnold Ltd"},
+		"1.128.0.0/11":   {"autonomous_system_number": uint32(1221), "autonomous_system_organization": "Telstra Pty Ltd"},
+		"89.160.20.0/24": {"autonomous_system_number": uint32(29518)},
+	})
+	db, err := Open([]string{city, asn})
+	if err != nil {
+		t.Fatalf("Open() unexpected error = %v", err)
+	}
+	defer db.Close()
+
+	s := func(v string) *string { return &v }
+	n := func(v int) *int { return &v }
+	tests := []struct {
+		name    string
+		address string
+		want    *api.GeoInfo
+	}{
+		{
+			name:    "city and ASN",
+			address: "81.2.69.142",
+			want:    &api.GeoInfo{Address: "81.2.69.142", Asn: n(20712), City: s("London"), Country: s("GB"), CountryName: s("United Kingdom"), Organization: s("Andrews & Arnold Ltd")},
+		},
+		{
+			name:    "IPv4-mapped address",
+			address: "::ffff:89.160.20.112",
+			want:    &api.GeoInfo{Address: "89.160.20.112", Asn: n(29518), Country: s("SE"), CountryName: s("Sweden")},
+		},
+		{
+			name:    "ASN only",
+			address: "1.130.0.1",
+			want:    &api.GeoInfo{Address: "1.130.0.1", Asn: n(1221), Organization: s("Telstra Pty Ltd")},
+		},
+		{name: "not found", address: "10.0.0.1"},
+		{name: "IPv6 address in IPv4 databases", address: "2001:db8::1"},
+		{name: "invalid address", address: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := db.Lookup(tt.address); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Lookup() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var none *DB
+	if got := none.Lookup("81.2.69.142"); got != nil {
+		t.Fatalf("Lookup() = %+v, want nil without databases", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.mmdb")
+	if err := os.WriteFile(invalid, binary.BigEndian.AppendUint32(nil, 42), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "valid", paths: []string{writeDB(t, "DBIP-City-Lite", nil)}},
+		{name: "missing file", paths: []string{filepath.Join(dir, "missing.mmdb")}, wantErr: true},
+		{name: "invalid file", paths: []string{invalid}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			db.Close()
+		})
+	}
+}
@@ -28,28 +28,37 @@ func SliceToMap(headerStrings []string) (map[string]string, error) {
 }
 
 // ToMap converts an http.Header to a "key:value" map.
-// It takes a list of headers to drop and a privacy mode flag to exclude headers that may reveal
-// sensitive information of the internal network. Note that enabling debug logging will log all dropped headers.
-func ToMap(headers http.Header, dropHeaders []string, privMode bool) map[string]string {
+// It takes a list of headers to drop and, in privacy mode, a function reporting the headers that
+// may reveal sensitive information of the internal network, called with the lower case names
+// (nil otherwise). Note that enabling debug logging will log all dropped headers.
+func ToMap(headers http.Header, dropHeaders []string, private func(header string) bool) map[string]string {
+	headerMap, _ := Filter(headers, dropHeaders, private)
+	return headerMap
+}
+
+// Filter works as ToMap, but it also returns the sorted names of the redacted headers.
+func Filter(headers http.Header, dropHeaders []string, private func(header string) bool) (map[string]string, []string) {
 	headerMap := make(map[string]string)
+	redacted := []string{}
 	normalizedDropHeaders := sliceToLower(dropHeaders)
 
 	for key, values := range headers {
 		lowerKey := strings.ToLower(key)
 		if slices.Contains(normalizedDropHeaders, lowerKey) {
 			logging.Debugf("Redact header '%s':'%s'", key, strings.Join(values, ","))
+			redacted = append(redacted, key)
 			continue
 		}
-		if privMode {
-			if isCloudflareHeader(lowerKey) || isXForwardedHeader(lowerKey) {
-				logging.Debugf("Redact header '%s':'%s' (privacy mode)", key, strings.Join(values, ","))
-				continue
-			}
+		if private != nil && private(lowerKey) {
+			logging.Debugf("Redact header '%s':'%s' (privacy mode)", key, strings.Join(values, ","))
+			redacted = append(redacted, key)
+			continue
 		}
 		headerMap[key] = strings.Join(values, ",")
 		logging.Tracef("Dump header '%s':'%s'", key, headerMap[key])
 	}
-	return headerMap
+	slices.Sort(redacted)
+	return headerMap, redacted
 }
 
 func sliceToLower(headers []string) []string {
@@ -60,21 +69,26 @@ func sliceToLower(headers []string) []string {
 	return lower
 }
 
-// isCloudflareHeader checks if a header is a Cloudflare-specific header that should be dropped in privacy mode.
+// IsXForwardedHeader checks if a header is an X-Forwarded or X-Real-IP header that should be dropped in privacy mode.
 // NOTE: it expects headers to be already normalized to lowercase.
-func isCloudflareHeader(header string) bool {
-	return strings.HasPrefix(header, "cf-")
+func IsXForwardedHeader(header string) bool {
+	return strings.HasPrefix(header, "x-forwarded-") || header == "x-real-ip"
 }
 
-// isXForwardedHeader checks if a header is an X-Forwarded or X-Real-IP header that should be dropped in privacy mode.
-// NOTE: it expects headers to be already normalized to lowercase.
-func isXForwardedHeader(header string) bool {
-	return strings.HasPrefix(header, "x-forwarded-") || header == "x-real-ip"
+// proxyHeaders are the headers set by proxies and CDNs, besides the X-Forwarded ones.
+var proxyHeaders = []string{"forwarded", "via", "cdn-loop", "true-client-ip", "x-client-ip"}
+
+// IsProxyHeader checks if a header is usually set by a proxy or a CDN, rather than by the client.
+// The headers of the edge providers are recognized by the edge package.
+func IsProxyHeader(header string) bool {
+	lowerKey := strings.ToLower(header)
+	return IsXForwardedHeader(lowerKey) || slices.Contains(proxyHeaders, lowerKey)
 }
 
-// GetRemoteHostInfo extracts the remote host information from the request, inspecting common proxy headers like CF-Connecting-IP, X-Real-IP, and X-Forwarded-For.
-// It returns a formatted string with the remote address and user agent.
-func GetRemoteHostInfo(r *http.Request) string {
+// GetRemoteHostInfo returns a formatted string with the remote address, user agent, method,
+// protocol and URL of the request. The remote address describes the client, e.g. the address
+// resolved through the trusted proxies and its location, or r.RemoteAddr if empty.
+func GetRemoteHostInfo(r *http.Request, remoteAddr string) string {
 	// Example of received headers:
 	// "Accept": "*/*",
 	// "Accept-Encoding": "gzip",
@@ -91,17 +105,9 @@ func GetRemoteHostInfo(r *http.Request) string {
 	// "X-Forwarded-Server": "traefik-73f98ac65-z1drx",
 	// "X-Real-Ip": "10.22.0.0"
 
-	remoteAddr := r.RemoteAddr
-	userAgent := r.Header.Get("User-Agent")
-
-	// Proxied through Cloudflare?
-	if remote := r.Header.Get("CF-Connecting-IP"); remote != "" {
-		remoteAddr = fmt.Sprintf("%s(%s) [%s]", remote, r.Header.Get("Cf-Ipcountry"), remoteAddr)
-	} else if remote := r.Header.Get("X-Real-Ip"); remote != "" {
-		remoteAddr = fmt.Sprintf("%s [%s]", remote, remoteAddr)
-	} else if remote := r.Header.Get("X-Forwarded-For"); remote != "" {
-		remoteAddr = fmt.Sprintf("%s [%s]", remote, remoteAddr)
+	if remoteAddr == "" {
+		remoteAddr = r.RemoteAddr
 	}
-
+	userAgent := r.Header.Get("User-Agent")
 	return fmt.Sprintf("%s %q - %s %s %q", remoteAddr, userAgent, r.Method, r.Proto, r.URL.String())
 }
@@ -4,7 +4,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
-	"strings"
 	"testing"
 )
 
@@ -114,7 +113,7 @@ func TestToMap(t *testing.T) {
 		name        string
 		headers     http.Header
 		dropHeaders []string
-		privMode    bool
+		private     func(string) bool
 		want        map[string]string
 	}{
 		{
@@ -123,7 +122,6 @@ func TestToMap(t *testing.T) {
 				"X-Custom": {"value"},
 			},
 			dropHeaders: []string{},
-			privMode:    false,
 			want: map[string]string{
 				"X-Custom": "value",
 			},
@@ -134,23 +132,8 @@ func TestToMap(t *testing.T) {
 				"X-Custom": {"value"},
 			},
 			dropHeaders: []string{"x-custom"},
-			privMode:    false,
 			want:        map[string]string{},
 		},
-		{
-			name: "privMode drops Cloudflare headers",
-			headers: http.Header{
-				"CF-Ray":           {"9cbdc3515d22baf3-MXP"},
-				"Cf-Visitor":       {"{\"scheme\":\"https\"}"},
-				"cf-Connecting-Ip": {"10.22.0.0"},
-				"X-Custom":         {"value"},
-			},
-			dropHeaders: []string{},
-			privMode:    true,
-			want: map[string]string{
-				"X-Custom": "value",
-			},
-		},
 		{
 			name: "privMode drops X-Forwarded headers",
 			headers: http.Header{
@@ -160,7 +143,7 @@ func TestToMap(t *testing.T) {
 				"X-Custom":          {"value"},
 			},
 			dropHeaders: []string{},
-			privMode:    true,
+			private:     IsXForwardedHeader,
 			want: map[string]string{
 				"X-Custom": "value",
 			},
@@ -174,7 +157,7 @@ func TestToMap(t *testing.T) {
 				"X-Custom":  {"value"},
 			},
 			dropHeaders: []string{},
-			privMode:    true,
+			private:     IsXForwardedHeader,
 			want: map[string]string{
 				"X-Custom": "value",
 			},
@@ -188,7 +171,6 @@ func TestToMap(t *testing.T) {
 				"X-Custom":        {"value"},
 			},
 			dropHeaders: []string{},
-			privMode:    false,
 			want: map[string]string{
 				"CF-Ray":          "9cbdc3515d22baf3-MXP",
 				"X-Forwarded-For": "10.22.0.0",
@@ -200,7 +182,7 @@ func TestToMap(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ToMap(tt.headers, tt.dropHeaders, tt.privMode)
+			got := ToMap(tt.headers, tt.dropHeaders, tt.private)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("ToMap() = %v, want %v", got, tt.want)
 			}
@@ -208,103 +190,70 @@ func TestToMap(t *testing.T) {
 	}
 }
 
+func TestFilter(t *testing.T) {
+	headers := http.Header{
+		"X-Forwarded-For": {"10.22.0.0"},
+		"Cookie":          {"session=abc"},
+		"X-Custom":        {"value"},
+	}
+
+	got, redacted := Filter(headers, []string{"cookie"}, IsXForwardedHeader)
+	if want := map[string]string{"X-Custom": "value"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Filter() = %v, want %v", got, want)
+	}
+	if want := []string{"Cookie", "X-Forwarded-For"}; !reflect.DeepEqual(redacted, want) {
+		t.Fatalf("Filter() redacted = %v, want %v", redacted, want)
+	}
+}
+
+func TestIsProxyHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "X-Forwarded-For", want: true},
+		{header: "x-real-ip", want: true},
+		{header: "Cdn-Loop", want: true},
+		{header: "Via", want: true},
+		{header: "Forwarded", want: true},
+		{header: "Accept", want: false},
+		{header: "X-Custom", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := IsProxyHeader(tt.header); got != tt.want {
+				t.Fatalf("IsProxyHeader(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetRemoteHostInfo(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/path?a=1", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("User-Agent", "curl/8.5.0")
+
 	tests := []struct {
 		name       string
 		remoteAddr string
-		headers    http.Header
-		method     string
-		urlString  string
-		expectedIP string
+		want       string
 	}{
 		{
-			name:       "uses CF-Connecting-IP with Cf-Ipcountry",
-			remoteAddr: "127.0.0.1:5000",
-			headers: http.Header{
-				"CF-Connecting-IP": {"1.2.3.4"},
-				"Cf-Ipcountry":     {"US"},
-				"X-Real-Ip":        {"5.6.7.8"},
-				"X-Forwarded-For":  {"9.10.11.12"},
-			},
-			method:     "GET",
-			urlString:  "http://example.com/",
-			expectedIP: "1.2.3.4(US)",
-		},
-		{
-			name:       "uses X-Real-IP when CF-Connecting-IP not available",
-			remoteAddr: "127.0.0.1:5000",
-			headers: http.Header{
-				"X-Real-Ip":       {"5.6.7.8"},
-				"X-Forwarded-For": {"9.10.11.12"},
-			},
-			method:     "GET",
-			urlString:  "http://example.com/",
-			expectedIP: "5.6.7.8",
-		},
-		{
-			name:       "uses X-Forwarded-For when CF-Connecting-IP and X-Real-IP not available",
-			remoteAddr: "127.0.0.1:5000",
-			headers: http.Header{
-				"X-Forwarded-For": {"9.10.11.12"},
-			},
-			method:     "GET",
-			urlString:  "http://example.com/",
-			expectedIP: "9.10.11.12",
-		},
-		{
-			name:       "uses r.RemoteAddr when no proxy headers available",
-			remoteAddr: "192.168.1.1:8080",
-			headers:    http.Header{},
-			method:     "GET",
-			urlString:  "http://example.com/",
-			expectedIP: "192.168.1.1:8080",
+			name:       "describes the client",
+			remoteAddr: "1.2.3.4(IT) x-forwarded-for [127.0.0.1:5000]",
+			want:       `1.2.3.4(IT) x-forwarded-for [127.0.0.1:5000] "curl/8.5.0" - GET HTTP/1.1 "http://example.com/path?a=1"`,
 		},
 		{
-			name:       "prefers cf-Connecting-IP over X-Real-IP",
-			remoteAddr: "127.0.0.1:5000",
-			headers: http.Header{
-				"cf-Connecting-IP": {"1.2.3.4"},
-				"cf-Ipcountry":     {"IT"},
-				"X-Real-Ip":        {"5.6.7.8"},
-				"X-Forwarded-For":  {"9.10.11.12"},
-			},
-			method:     "GET",
-			urlString:  "http://example.com/",
-			expectedIP: "1.2.3.4(IT)",
-		},
-		{
-			name:       "prefers x-Real-ip over X-Forwarded-For when CF-Connecting-IP missing",
-			remoteAddr: "127.0.0.1:5000",
-			headers: http.Header{
-				"x-Real-ip":       {"5.6.7.8"},
-				"X-Forwarded-For": {"9.10.11.12"},
-			},
-			method:     "GET",
-			urlString:  "http://example.com/",
-			expectedIP: "5.6.7.8",
+			name: "falls back to r.RemoteAddr",
+			want: `127.0.0.1:5000 "curl/8.5.0" - GET HTTP/1.1 "http://example.com/path?a=1"`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(tt.method, tt.urlString, nil)
-			req.RemoteAddr = tt.remoteAddr
-			for key, values := range tt.headers {
-				for _, value := range values {
-					req.Header.Add(key, value)
-				}
-			}
-
-			got := GetRemoteHostInfo(req)
-
-			// Verify that the expected IP is contained in the result
-			if !strings.Contains(got, tt.expectedIP) {
-				t.Fatalf("GetRemoteHostInfo() = %q, expected to contain IP %q", got, tt.expectedIP)
-			}
-
-			// Verify the format includes method and proto
-			if !strings.Contains(got, tt.method) {
-				t.Fatalf("GetRemoteHostInfo() = %q, expected to contain method %q", got, tt.method)
+			if got := GetRemoteHostInfo(req, tt.remoteAddr); got != tt.want {
+				t.Fatalf("GetRemoteHostInfo() = %q, want %q", got, tt.want)
 			}
 		})
 	}
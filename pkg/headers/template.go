@@ -0,0 +1,126 @@
+package headers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Template is a custom response header value, evaluated for each request as a Go text/template.
+// Values without template actions are returned as they are.
+type Template struct {
+	raw  string
+	tmpl *template.Template
+}
+
+// TemplateData is the data available to the header value templates.
+type TemplateData struct {
+	// Request is the received HTTP request.
+	Request TemplateRequest
+	// Hostname is the host name of the server (e.g. the pod name in Kubernetes).
+	Hostname string
+	// Now is the time the request is served.
+	Now Timestamp
+	// RemoteIP is the IP address of the client, resolved through the trusted proxies.
+	RemoteIP string
+	// PeerIP is the IP address of the TCP peer connected to the server.
+	PeerIP string
+	// UUID is a random (version 4) UUID, different for each request.
+	UUID string
+}
+
+// Env returns the value of the environment variable named by the key.
+func (TemplateData) Env(key string) string {
+	return os.Getenv(key)
+}
+
+// TemplateRequest exposes the received HTTP request to the header value templates.
+type TemplateRequest struct {
+	Method string
+	Host   string
+	Path   string
+	Proto  string
+	header http.Header
+	query  func(string) string
+}
+
+// Header returns the first value of the named request header, or an empty string.
+func (r TemplateRequest) Header(name string) string {
+	return r.header.Get(name)
+}
+
+// Query returns the first value of the named query parameter, or an empty string.
+func (r TemplateRequest) Query(name string) string {
+	return r.query(name)
+}
+
+// Timestamp is a time.Time printed in RFC 3339 format.
+type Timestamp struct {
+	time.Time
+}
+
+func (t Timestamp) String() string {
+	return t.Format(time.RFC3339)
+}
+
+var hostname, _ = os.Hostname()
+
+// ParseTemplates parses the values of the custom headers as Go templates.
+// Returns an error if any value is not a valid template.
+func ParseTemplates(headers map[string]string) (map[string]*Template, error) {
+	templates := make(map[string]*Template, len(headers))
+	for key, value := range headers {
+		t := &Template{raw: value}
+		if strings.Contains(value, "{{") {
+			tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template for header '%s': %w", key, err)
+			}
+			t.tmpl = tmpl
+		}
+		templates[key] = t
+	}
+	return templates, nil
+}
+
+// NewTemplateData collects the template data of the request, whose client address has been
+// resolved to remoteIP.
+func NewTemplateData(r *http.Request, remoteIP string) TemplateData {
+	peerIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peerIP = r.RemoteAddr
+	}
+	return TemplateData{
+		Request: TemplateRequest{
+			Method: r.Method,
+			Host:   r.Host,
+			Path:   r.URL.Path,
+			Proto:  r.Proto,
+			header: r.Header,
+			query:  r.URL.Query().Get,
+		},
+		Hostname: hostname,
+		Now:      Timestamp{time.Now()},
+		RemoteIP: remoteIP,
+		PeerIP:   peerIP,
+		UUID:     uuid.NewString(),
+	}
+}
+
+// Execute evaluates the header value for the request data.
+func (t *Template) Execute(data TemplateData) (string, error) {
+	if t.tmpl == nil {
+		return t.raw, nil
+	}
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
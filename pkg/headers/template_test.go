@@ -0,0 +1,100 @@
+package headers
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestParseTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "literal value",
+			input: map[string]string{"X-Custom": "value"},
+		},
+		{
+			name:  "template value",
+			input: map[string]string{"X-Served-By": "{{.Hostname}}"},
+		},
+		{
+			name:    "unterminated action",
+			input:   map[string]string{"X-Served-By": "{{.Hostname"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown function",
+			input:   map[string]string{"X-Served-By": "{{hostname}}"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTemplates(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(got) != len(tt.input) {
+				t.Fatalf("ParseTemplates() = %v, want %d templates", got, len(tt.input))
+			}
+		})
+	}
+}
+
+func TestTemplateExecute(t *testing.T) {
+	t.Setenv("HEADERTRACE_TEST_POD", "pod-1")
+
+	req := httptest.NewRequest("GET", "http://example.com/foo?id=42", nil)
+	req.RemoteAddr = "192.168.1.1:5000"
+	req.Header.Set("X-Request-Id", "abc123")
+	data := NewTemplateData(req, "203.0.113.9")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantRe  string
+		wantErr bool
+	}{
+		{name: "literal", value: "value", want: "value"},
+		{name: "request header", value: "{{.Request.Header `X-Request-Id`}}", want: "abc123"},
+		{name: "missing request header", value: `{{.Request.Header "X-Missing"}}`, want: ""},
+		{name: "fallback value", value: `{{or (.Request.Header "X-Missing") "none"}}`, want: "none"},
+		{name: "request fields", value: "{{.Request.Method}} {{.Request.Host}}{{.Request.Path}}", want: "GET example.com/foo"},
+		{name: "query parameter", value: `{{.Request.Query "id"}}`, want: "42"},
+		{name: "hostname", value: "{{.Hostname}}", want: hostname},
+		{name: "remote IP", value: "{{.RemoteIP}}", want: "203.0.113.9"},
+		{name: "peer IP", value: "{{.PeerIP}}", want: "192.168.1.1"},
+		{name: "environment variable", value: `{{.Env "HEADERTRACE_TEST_POD"}}`, want: "pod-1"},
+		{name: "now", value: "{{.Now}}", wantRe: `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`},
+		{name: "now unix", value: "{{.Now.Unix}}", wantRe: `^\d+$`},
+		{name: "uuid", value: "{{.UUID}}", wantRe: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`},
+		{name: "unknown field", value: "{{.Missing}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := ParseTemplates(map[string]string{"X-Test": tt.value})
+			if err != nil {
+				t.Fatalf("ParseTemplates() unexpected error = %v", err)
+			}
+			got, err := templates["X-Test"].Execute(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantRe != "" {
+				if !regexp.MustCompile(tt.wantRe).MatchString(got) {
+					t.Fatalf("Execute() = %q, expected to match %q", got, tt.wantRe)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("Execute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
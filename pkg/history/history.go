@@ -0,0 +1,126 @@
+package history
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// IDHeader is the response header carrying the ID of the recorded exchange.
+const IDHeader = "Headertrace-Request-Id"
+
+// redactedValue replaces the values of the credential headers in the redacted exchanges.
+const redactedValue = "[redacted]"
+
+// credentialHeaders carry the credentials of the clients, in canonical form.
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// History is a bounded ring buffer of the echoed exchanges: once full, the oldest exchanges
+// are evicted. It is safe for concurrent use.
+type History struct {
+	mu        sync.Mutex
+	exchanges []api.Exchange
+	next      int
+	size      int
+	lastID    atomic.Int64
+	recorded  int64
+}
+
+// New returns a History keeping the given number of exchanges.
+func New(capacity int) (*History, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("invalid history size '%d', expected a positive number", capacity)
+	}
+	return &History{exchanges: make([]api.Exchange, capacity)}, nil
+}
+
+// NextID returns the ID of a new exchange, before it is recorded with Add.
+func (h *History) NextID() int64 {
+	return h.lastID.Add(1)
+}
+
+// Add records the exchange, evicting the oldest one if the history is full.
+func (h *History) Add(exchange api.Exchange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.exchanges[h.next] = exchange
+	h.next = (h.next + 1) % len(h.exchanges)
+	h.size = min(h.size+1, len(h.exchanges))
+	h.recorded++
+}
+
+// List returns the summaries of the recorded exchanges, from the most recent one.
+func (h *History) List() api.ExchangeList {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	summaries := make([]api.ExchangeSummary, 0, h.size)
+	for _, e := range h.all() {
+		summary := api.ExchangeSummary{
+			Id:       e.Id,
+			Method:   e.Response.Method,
+			Path:     e.Response.Path,
+			Received: e.Received,
+			Remote:   e.Remote,
+			Status:   e.Status,
+		}
+		if ua, ok := e.Response.Headers["User-Agent"]; ok {
+			summary.UserAgent = &ua
+		}
+		summaries = append(summaries, summary)
+	}
+	slices.SortFunc(summaries, func(a, b api.ExchangeSummary) int {
+		return cmp.Compare(b.Id, a.Id)
+	})
+	return api.ExchangeList{Capacity: len(h.exchanges), Exchanges: summaries, Recorded: h.recorded}
+}
+
+// Get returns the recorded exchange with the given ID.
+func (h *History) Get(id int64) (api.Exchange, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.all() {
+		if e.Id == id {
+			return e, true
+		}
+	}
+	return api.Exchange{}, false
+}
+
+// Clear removes all the recorded exchanges. The IDs of the new exchanges keep growing.
+func (h *History) Clear() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.exchanges)
+	h.next, h.size = 0, 0
+}
+
+// all returns the recorded exchanges, from the oldest one. The lock must be held.
+func (h *History) all() []api.Exchange {
+	start := (h.next - h.size + len(h.exchanges)) % len(h.exchanges)
+	exchanges := make([]api.Exchange, 0, h.size)
+	for i := range h.size {
+		exchanges = append(exchanges, h.exchanges[(start+i)%len(h.exchanges)])
+	}
+	return exchanges
+}
+
+// Redact returns the exchange without the credentials of the client, to be recorded: the values
+// of the Authorization, Proxy-Authorization and Cookie headers are replaced and the decoded JWTs
+// are dropped. The exchange passed is not modified.
+func Redact(exchange api.Exchange) api.Exchange {
+	headers := maps.Clone(exchange.Response.Headers)
+	for name := range headers {
+		if slices.Contains(credentialHeaders, http.CanonicalHeaderKey(name)) {
+			headers[name] = redactedValue
+		}
+	}
+	exchange.Response.Headers = headers
+	exchange.Response.Jwt = nil
+	return exchange
+}
@@ -0,0 +1,132 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		wantErr  bool
+	}{
+		{name: "positive size", capacity: 10},
+		{name: "zero size", capacity: 0, wantErr: true},
+		{name: "negative size", capacity: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.capacity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New(%d) error = %v, wantErr %v", tt.capacity, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	record := func(h *History, path string) int64 {
+		id := h.NextID()
+		h.Add(api.Exchange{
+			Id:       id,
+			Received: "2025-05-04T10:15:30Z",
+			Remote:   "203.0.113.9",
+			Response: api.HeaderResponse{
+				Method:  "POST",
+				Path:    path,
+				Headers: map[string]string{"User-Agent": "GitHub-Hookshot/044aadd"},
+			},
+			Status: 200,
+		})
+		return id
+	}
+	ids := func(list api.ExchangeList) []int64 {
+		var ids []int64
+		for _, e := range list.Exchanges {
+			ids = append(ids, e.Id)
+		}
+		return ids
+	}
+
+	h, err := New(3)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for i := range 5 {
+		record(h, fmt.Sprintf("/hook/%d", i+1))
+	}
+
+	list := h.List()
+	if got, want := ids(list), []int64{5, 4, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("List() IDs = %v, want %v", got, want)
+	}
+	if list.Capacity != 3 || list.Recorded != 5 {
+		t.Fatalf("List() capacity = %d, recorded = %d, want 3 and 5", list.Capacity, list.Recorded)
+	}
+	ua := "GitHub-Hookshot/044aadd"
+	want := api.ExchangeSummary{
+		Id: 5, Method: "POST", Path: "/hook/5", Received: "2025-05-04T10:15:30Z", Remote: "203.0.113.9", Status: 200, UserAgent: &ua,
+	}
+	if !reflect.DeepEqual(list.Exchanges[0], want) {
+		t.Fatalf("List() first exchange = %+v, want %+v", list.Exchanges[0], want)
+	}
+
+	if e, ok := h.Get(3); !ok || e.Response.Path != "/hook/3" {
+		t.Fatalf("Get(3) = %+v, %v, want /hook/3", e, ok)
+	}
+	if _, ok := h.Get(2); ok {
+		t.Fatalf("Get(2) found an evicted exchange")
+	}
+
+	h.Clear()
+	if got := ids(h.List()); got != nil {
+		t.Fatalf("List() after Clear() IDs = %v, want none", got)
+	}
+	if _, ok := h.Get(5); ok {
+		t.Fatalf("Get(5) found a cleared exchange")
+	}
+	if id := record(h, "/hook/6"); id != 6 {
+		t.Fatalf("ID after Clear() = %d, want 6", id)
+	}
+	if got, want := ids(h.List()), []int64{6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("List() IDs = %v, want %v", got, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	claims := []api.JWTInfo{{Source: "authorization"}}
+	exchange := api.Exchange{
+		Id: 1,
+		Response: api.HeaderResponse{
+			Headers: map[string]string{
+				"Authorization":       "Bearer eyJhbGciOiJIUzI1NiJ9.e30.ZRrHA1JJJW8opsbCGfG_HACGpVUMN_a9IV7pAx_Zmeo",
+				"Cookie":              "session=abc",
+				"Proxy-Authorization": "Basic YWxpY2U6c2VjcmV0",
+				"User-Agent":          "curl/8.5.0",
+			},
+			Jwt: &claims,
+		},
+	}
+
+	got := Redact(exchange)
+	want := map[string]string{
+		"Authorization":       "[redacted]",
+		"Cookie":              "[redacted]",
+		"Proxy-Authorization": "[redacted]",
+		"User-Agent":          "curl/8.5.0",
+	}
+	if !reflect.DeepEqual(got.Response.Headers, want) {
+		t.Fatalf("Redact() headers = %v, want %v", got.Response.Headers, want)
+	}
+	if got.Response.Jwt != nil {
+		t.Fatalf("Redact() kept the decoded JWTs")
+	}
+	if exchange.Response.Headers["Cookie"] != "session=abc" || exchange.Response.Jwt == nil {
+		t.Fatalf("Redact() modified the exchange passed")
+	}
+}
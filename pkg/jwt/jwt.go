@@ -0,0 +1,400 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // register the hashes of the signature algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// Sources of the tokens.
+const (
+	// SourceAuthorization is the Bearer token of the Authorization header.
+	SourceAuthorization = "authorization"
+	// SourceCookie prefixes the name of the cookie holding the token.
+	SourceCookie = "cookie:"
+)
+
+// Signature verification outcomes.
+const (
+	SignatureValid      = "valid"
+	SignatureInvalid    = "invalid"
+	SignatureUnverified = "unverified"
+)
+
+// Expiry statuses of the tokens, from the exp and nbf claims.
+const (
+	ExpiryActive      = "active"
+	ExpiryExpired     = "expired"
+	ExpiryNotYetValid = "not-yet-valid"
+	ExpiryNone        = "no-expiry"
+)
+
+// Config holds the JWT decoding settings.
+type Config struct {
+	// Cookies are the names of the cookies holding JWTs, besides the Authorization Bearer token.
+	Cookies []string
+	// JWKS is the path of the JSON Web Key Set file verifying the signatures, optional.
+	JWKS string
+	// Secret is the shared secret verifying the HMAC signatures, optional.
+	Secret string
+}
+
+// key is a verification key of the JWKS.
+type key struct {
+	id     string
+	alg    string
+	public crypto.PublicKey
+	secret []byte
+}
+
+// Decoder decodes the JWTs of the requests, verifying their signatures if keys are configured.
+type Decoder struct {
+	cookies []string
+	keys    []key
+	jwks    bool
+	secret  []byte
+}
+
+// New validates the configuration, loading the JWKS file, and returns the corresponding Decoder.
+func New(cfg Config) (*Decoder, error) {
+	d := &Decoder{cookies: cfg.Cookies}
+	if cfg.Secret != "" {
+		d.secret = []byte(cfg.Secret)
+	}
+	if cfg.JWKS != "" {
+		data, err := os.ReadFile(cfg.JWKS)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read JWKS: %w", err)
+		}
+		if d.keys, err = parseJWKS(data); err != nil {
+			return nil, fmt.Errorf("invalid JWKS '%s': %w", cfg.JWKS, err)
+		}
+		d.jwks = true
+	}
+	return d, nil
+}
+
+// Decode returns the decoded JWTs of the Authorization Bearer token and the configured cookies,
+// with their expiry status at the given time. Values that are not JWTs, such as opaque tokens,
+// are skipped. The raw tokens and their signatures are never returned, so that the claims can
+// be shown while the headers carrying them are redacted.
+func (d *Decoder) Decode(r *http.Request, now time.Time) []api.JWTInfo {
+	var infos []api.JWTInfo
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "bearer") {
+		if info, ok := d.decode(SourceAuthorization, strings.TrimSpace(token), now); ok {
+			infos = append(infos, info)
+		}
+	}
+	for _, name := range d.cookies {
+		for _, cookie := range r.CookiesNamed(name) {
+			if info, ok := d.decode(SourceCookie+name, cookie.Value, now); ok {
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos
+}
+
+// decode decodes a compact JWS, returning false if the token is not a JWT.
+func (d *Decoder) decode(source, token string, now time.Time) (api.JWTInfo, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return api.JWTInfo{}, false
+	}
+	var header map[string]interface{}
+	if err := decodeSegment(parts[0], &header); err != nil || header == nil {
+		return api.JWTInfo{}, false
+	}
+
+	info := api.JWTInfo{Source: source, Header: &header, Signature: SignatureUnverified}
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		msg := "invalid claims: payload is not a JSON object"
+		info.Error = &msg
+		return info, true
+	}
+	info.Claims = &claims
+	if iss, ok := claims["iss"].(string); ok {
+		info.Issuer = &iss
+	}
+	if sub, ok := claims["sub"].(string); ok {
+		info.Subject = &sub
+	}
+	switch aud := claims["aud"].(type) {
+	case string:
+		info.Audience = &[]string{aud}
+	case []interface{}:
+		audience := []string{}
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		info.Audience = &audience
+	}
+
+	expiry := ExpiryNone
+	if exp, ok := numericDate(claims["exp"]); ok {
+		expiresAt := exp.UTC().Format(time.RFC3339)
+		info.ExpiresAt = &expiresAt
+		expiry = ExpiryActive
+		if !now.Before(exp) {
+			expiry = ExpiryExpired
+		}
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Before(nbf) {
+		expiry = ExpiryNotYetValid
+	}
+	info.Expiry = &expiry
+
+	alg, _ := header["alg"].(string)
+	kid, _ := header["kid"].(string)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		info.Signature = SignatureInvalid
+		msg := "invalid signature encoding"
+		info.Error = &msg
+		return info, true
+	}
+	status, err := d.verify(alg, kid, []byte(parts[0]+"."+parts[1]), signature)
+	info.Signature = status
+	if err != nil {
+		msg := err.Error()
+		info.Error = &msg
+	}
+	return info, true
+}
+
+// verify checks the signature against the configured secret and keys. Keys are never used
+// outside of their type, not to fall for algorithm confusion (e.g. a RSA key as HMAC secret).
+func (d *Decoder) verify(alg, kid string, input, signature []byte) (string, error) {
+	if alg == "none" {
+		if d.secret != nil || d.jwks {
+			return SignatureInvalid, fmt.Errorf("unsecured token (alg none)")
+		}
+		return SignatureUnverified, fmt.Errorf("unsecured token (alg none)")
+	}
+	hash, ok := hashes[alg]
+	if !ok && alg != "EdDSA" {
+		return SignatureUnverified, fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+
+	var candidates []key
+	if strings.HasPrefix(alg, "HS") && d.secret != nil {
+		candidates = append(candidates, key{secret: d.secret})
+	}
+	for _, k := range d.keys {
+		if (kid == "" || k.id == kid) && (k.alg == "" || k.alg == alg) && fits(k, alg) {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) == 0 {
+		switch {
+		case d.jwks && kid != "":
+			return SignatureInvalid, fmt.Errorf("no %s key with kid '%s' in the JWKS", alg, kid)
+		case d.jwks:
+			return SignatureInvalid, fmt.Errorf("no %s key in the JWKS", alg)
+		case strings.HasPrefix(alg, "HS"):
+			return SignatureUnverified, fmt.Errorf("no shared secret configured")
+		default:
+			return SignatureUnverified, fmt.Errorf("no JWKS configured")
+		}
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(input)
+		digest = h.Sum(nil)
+	}
+	for _, k := range candidates {
+		if verifyKey(k, alg, hash, input, digest, signature) {
+			return SignatureValid, nil
+		}
+	}
+	return SignatureInvalid, fmt.Errorf("signature does not match")
+}
+
+// hashes are the hash functions of the supported algorithms, but EdDSA.
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// curves are the curves of the ECDSA algorithms.
+var curves = map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}
+
+// fits reports whether the key type matches the algorithm.
+func fits(k key, alg string) bool {
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return curves[alg] == pub.Curve
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return k.secret != nil && strings.HasPrefix(alg, "HS")
+}
+
+func verifyKey(k key, alg string, hash crypto.Hash, input, digest, signature []byte) bool {
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// The signature is the concatenation of R and S, each as long as the curve order
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, input, signature)
+	}
+	mac := hmac.New(hash.New, k.secret)
+	mac.Write(input)
+	return hmac.Equal(mac.Sum(nil), signature)
+}
+
+// jsonWebKey is a JSON Web Key (RFC 7517) of a supported type.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS parses the signature keys of a JWKS: RSA, EC (P-256, P-384, P-521), OKP (Ed25519)
+// and oct. Encryption keys and keys of other types are skipped.
+func parseJWKS(data []byte) ([]key, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []key
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		k := key{id: jwk.Kid, alg: jwk.Alg}
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			k.public, err = rsaKey(jwk)
+		case "EC":
+			k.public, err = ecKey(jwk)
+		case "OKP":
+			if jwk.Crv != "Ed25519" {
+				continue
+			}
+			var x []byte
+			if x, err = base64.RawURLEncoding.DecodeString(jwk.X); err == nil && len(x) != ed25519.PublicKeySize {
+				err = fmt.Errorf("invalid Ed25519 key length %d", len(x))
+			}
+			k.public = ed25519.PublicKey(x)
+		case "oct":
+			if k.secret, err = base64.RawURLEncoding.DecodeString(jwk.K); err == nil && len(k.secret) == 0 {
+				err = fmt.Errorf("empty secret")
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signature keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil || len(n) == 0 {
+		return nil, fmt.Errorf("invalid RSA modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("invalid RSA exponent")
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if exponent.Int64() > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func ecKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve '%s'", jwk.Crv)
+	}
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	size := (curve.Params().BitSize + 7) / 8
+	if errX != nil || errY != nil || len(x) != size || len(y) != size {
+		return nil, fmt.Errorf("invalid %s point", jwk.Crv)
+	}
+	// The uncompressed point encoding validates that the point is on the curve
+	point := append([]byte{4}, append(x, y...)...)
+	return ecdsa.ParseUncompressedPublicKey(curve, point)
+}
+
+// decodeSegment decodes a base64url JSON segment of the token.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// numericDate converts a NumericDate claim, in seconds since the epoch.
+func numericDate(v interface{}) (time.Time, bool) {
+	seconds, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), true
+}
@@ -0,0 +1,120 @@
+package lint
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/capture"
+)
+
+// Severities of the lint issues.
+const (
+	// Error flags a violation of the HTTP specifications.
+	Error = "error"
+	// Warning flags a discouraged or risky construct.
+	Warning = "warning"
+)
+
+// Rules of the lint issues.
+const (
+	RuleNonASCII          = "non-ascii"
+	RuleObsFold           = "obs-fold"
+	RuleRepeatedSingleton = "repeated-singleton"
+	RuleHopByHop          = "hop-by-hop"
+	RuleOversizedValue    = "oversized-value"
+)
+
+// MaxValueLength is the length above which header values are flagged as oversized: many
+// servers and proxies reject header lines longer than 8 KiB.
+const MaxValueLength = 8192
+
+// singletons are the fields that must not be repeated in a request, in lower case. Host is
+// left out, as the Go HTTP server rejects repeated Host fields.
+var singletons = []string{
+	"content-length", "content-type", "authorization", "proxy-authorization",
+	"max-forwards", "if-modified-since", "if-unmodified-since", "referer", "user-agent", "from",
+	"date", "origin",
+}
+
+// hopByHop are the fields meant for the next hop only, that proxies must not forward, in lower
+// case. Connection is left out, as the last proxy sets its own.
+var hopByHop = []string{"keep-alive", "proxy-connection", "proxy-authorization", "te"}
+
+// proxyHeaders reveal that the request went through a proxy, in lower case.
+var proxyHeaders = []string{"via", "forwarded", "x-forwarded-for"}
+
+// Check flags the protocol problems of the request header fields (RFC 9110, RFC 9112).
+// The fields are inspected as received on the wire if raw holds the request header block,
+// otherwise as parsed by the server. The messages never include the field values, that
+// may be redacted.
+//
+// Only the problems the Go HTTP server lets through are checked: it rejects invalid field
+// names, whitespace before the colon, control characters in the values and repeated Host
+// fields.
+func Check(r *http.Request, raw []byte) []api.LintIssue {
+	var fields []capture.Field
+	if raw != nil {
+		for _, f := range capture.ParseHeader(raw) {
+			f.Value = strings.Trim(f.Value, " \t")
+			fields = append(fields, f)
+		}
+	} else {
+		fields = append(fields, capture.Field{Name: "Host", Value: r.Host})
+		keys := make([]string, 0, len(r.Header))
+		for key := range r.Header {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			for _, value := range r.Header[key] {
+				fields = append(fields, capture.Field{Name: key, Value: value})
+			}
+		}
+	}
+
+	var issues []api.LintIssue
+	add := func(rule, severity, name, format string, args ...any) {
+		issue := api.LintIssue{Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)}
+		if name != "" {
+			issue.Field = &name
+		}
+		issues = append(issues, issue)
+	}
+
+	counts := map[string]int{}
+	proxied := false
+	for _, f := range fields {
+		lower := strings.ToLower(f.Name)
+		counts[lower]++
+		if slices.Contains(proxyHeaders, lower) {
+			proxied = true
+		}
+
+		if f.Folded {
+			add(RuleObsFold, Error, f.Name, "value continued on the next line (obsolete line folding)")
+		}
+		if i := strings.IndexFunc(f.Value, func(c rune) bool { return c >= 0x80 }); i >= 0 {
+			add(RuleNonASCII, Warning, f.Name, "value contains non-ASCII bytes from byte %d", i)
+		}
+		if len(f.Value) > MaxValueLength {
+			add(RuleOversizedValue, Warning, f.Name, "value is %d bytes long, more than %d", len(f.Value), MaxValueLength)
+		}
+	}
+
+	for _, name := range singletons {
+		if counts[name] > 1 {
+			add(RuleRepeatedSingleton, Error, http.CanonicalHeaderKey(name), "field appears %d times, but allows a single value", counts[name])
+		}
+	}
+	if proxied {
+		for _, name := range hopByHop {
+			if counts[name] > 0 {
+				add(RuleHopByHop, Warning, http.CanonicalHeaderKey(name), "hop-by-hop field forwarded by a proxy")
+			}
+		}
+	}
+	return issues
+}
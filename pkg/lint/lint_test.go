@@ -0,0 +1,186 @@
+package lint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgiudici/headertrace/pkg/capture"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		header http.Header
+		want   []string
+	}{
+		{
+			name: "conformant request",
+			raw:  "GET / HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\nConnection: keep-alive\r\n\r\n",
+		},
+		{
+			name: "obsolete line folding",
+			raw:  "GET / HTTP/1.1\r\nHost: example.com\r\nX-Folded: a\r\n  b\r\n\r\n",
+			want: []string{"obs-fold error X-Folded"},
+		},
+		{
+			name: "non-ASCII value",
+			raw:  "GET / HTTP/1.1\nHost: example.com\nX-Latin: caf\xe9\n\n",
+			want: []string{"non-ascii warning X-Latin"},
+		},
+		{
+			name: "repeated singletons",
+			raw:  "GET / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 0\r\nContent-Length: 0\r\nAuthorization: a\r\nAuthorization: b\r\nAccept: a\r\nAccept: b\r\n\r\n",
+			want: []string{"repeated-singleton error Content-Length", "repeated-singleton error Authorization"},
+		},
+		{
+			name: "hop-by-hop fields forwarded by a proxy",
+			raw:  "GET / HTTP/1.1\r\nHost: example.com\r\nVia: 1.1 proxy\r\nKeep-Alive: timeout=5\r\nProxy-Authorization: Basic Zm9v\r\nConnection: close\r\n\r\n",
+			want: []string{"hop-by-hop warning Keep-Alive", "hop-by-hop warning Proxy-Authorization"},
+		},
+		{
+			name: "hop-by-hop fields from a direct client",
+			raw:  "GET / HTTP/1.1\r\nHost: example.com\r\nKeep-Alive: timeout=5\r\n\r\n",
+		},
+		{
+			name: "oversized value",
+			raw:  "GET / HTTP/1.1\r\nHost: example.com\r\nCookie: " + strings.Repeat("a", MaxValueLength+1) + "\r\n\r\n",
+			want: []string{"oversized-value warning Cookie"},
+		},
+		{
+			name:   "parsed headers",
+			header: http.Header{"X-Latin": {"caf\xe9"}, "Forwarded": {"for=192.0.2.60"}, "Te": {"trailers"}},
+			want:   []string{"non-ascii warning X-Latin", "hop-by-hop warning Te"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			var raw []byte
+			if tt.raw != "" {
+				raw = []byte(tt.raw)
+			} else {
+				r.Header = tt.header
+			}
+
+			var got []string
+			for _, issue := range Check(r, raw) {
+				if issue.Field == nil || issue.Message == "" {
+					t.Fatalf("Check() issue = %+v, expected a field and a message", issue)
+				}
+				got = append(got, issue.Rule+" "+issue.Severity+" "+*issue.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCheckServer sends the requests to a Go HTTP server, as headertrace does, checking that
+// the problems are reported for the requests reaching the handler.
+func TestCheckServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _ := capture.FromRequest(r)
+			for _, issue := range Check(r, conn.Header(r)) {
+				fmt.Fprintf(w, "%s %s %s\n", issue.Rule, issue.Severity, *issue.Field)
+			}
+		}),
+		ConnContext: capture.ConnContext,
+	}
+	go func() { _ = srv.Serve(capture.NewListener(l, capture.DefaultLimit)) }()
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		request    string
+		wantStatus int
+		want       []string
+	}{
+		{
+			name:       "obsolete line folding",
+			request:    "GET / HTTP/1.1\r\nHost: x\r\nX-Folded: a\r\n  b\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"obs-fold error X-Folded"},
+		},
+		{
+			name:       "non-ASCII value",
+			request:    "GET / HTTP/1.1\r\nHost: x\r\nX-Latin: caf\xe9\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"non-ascii warning X-Latin"},
+		},
+		{
+			name:       "repeated singleton",
+			request:    "GET / HTTP/1.1\r\nHost: x\r\nAuthorization: a\r\nAuthorization: b\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"repeated-singleton error Authorization"},
+		},
+		{
+			name:       "invalid field name rejected",
+			request:    "GET / HTTP/1.1\r\nHost: x\r\nX-Bad Name: a\r\n\r\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "whitespace before the colon rejected",
+			request:    "GET / HTTP/1.1\r\nHost: x\r\nX-Space : a\r\n\r\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "control character rejected",
+			request:    "GET / HTTP/1.1\r\nHost: x\r\nX-Ctl: a\x01b\r\n\r\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "repeated Host rejected",
+			request:    "GET / HTTP/1.1\r\nHost: x\r\nHost: y\r\n\r\n",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.WriteString(conn, tt.request); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("ReadResponse() error = %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if got := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n"); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("problems = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
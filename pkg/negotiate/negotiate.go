@@ -0,0 +1,344 @@
+package negotiate
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// Header names of the proactive negotiation (RFC 9110, section 12.5).
+const (
+	Accept         = "Accept"
+	AcceptCharset  = "Accept-Charset"
+	AcceptEncoding = "Accept-Encoding"
+	AcceptLanguage = "Accept-Language"
+)
+
+// Identity is the content coding of the unencoded representations: it is acceptable unless
+// excluded by the Accept-Encoding header, and always available as the last resort.
+const Identity = "identity"
+
+var (
+	token         = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+	languageRange = regexp.MustCompile(`^(\*|[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*)$`)
+	qvalue        = regexp.MustCompile(`^(0(\.[0-9]{0,3})?|1(\.0{0,3})?)$`)
+)
+
+// Config holds the values supported by the server, in order of preference.
+type Config struct {
+	Types     []string
+	Languages []string
+	Encodings []string
+	Charsets  []string
+}
+
+// Negotiator selects the variants of the supported values acceptable by the requests.
+type Negotiator struct {
+	fields []*field
+}
+
+// field is an Accept-* header, with the syntax of its elements and the supported values.
+type field struct {
+	name      string
+	parse     func(value string, params []string) (string, error)
+	match     func(rng, value string) int
+	fallback  func(value string) float64
+	supported []string
+}
+
+// entry is an element of an Accept-* header with its weight.
+type entry struct {
+	value string
+	q     float64
+}
+
+// New returns a Negotiator for the supported values: media types without wildcards,
+// language tags, content codings and charsets.
+func New(cfg Config) (*Negotiator, error) {
+	n := &Negotiator{fields: []*field{
+		{name: Accept, parse: parseMediaRange, match: matchMediaRange},
+		{name: AcceptLanguage, parse: parseLanguageRange, match: matchLanguageRange},
+		{name: AcceptEncoding, parse: parseToken, match: matchToken, fallback: identity},
+		{name: AcceptCharset, parse: parseToken, match: matchToken},
+	}}
+	for i, values := range [][]string{cfg.Types, cfg.Languages, cfg.Encodings, cfg.Charsets} {
+		f := n.fields[i]
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			elem, params := split(value, ';')
+			v, err := f.parse(elem, params)
+			if err != nil || strings.Contains(v, "*") {
+				return nil, fmt.Errorf("invalid %s value '%s', expected %s", f.name, value, f.expected())
+			}
+			if !slices.Contains(f.supported, v) {
+				f.supported = append(f.supported, v)
+			}
+		}
+		if f.name == AcceptEncoding && len(f.supported) > 0 && !slices.Contains(f.supported, Identity) {
+			f.supported = append(f.supported, Identity)
+		}
+	}
+	return n, nil
+}
+
+// expected describes the supported values of the field.
+func (f *field) expected() string {
+	switch f.name {
+	case Accept:
+		return "a media type (type/subtype)"
+	case AcceptLanguage:
+		return "a language tag (e.g. en-US)"
+	case AcceptEncoding:
+		return "a content coding (e.g. gzip)"
+	}
+	return "a charset (e.g. utf-8)"
+}
+
+// Analyze parses the Accept, Accept-Language, Accept-Encoding and Accept-Charset headers into
+// lists sorted by decreasing q-value, along with their malformed elements, and selects the
+// variant of the supported values each of them prefers. Returns nil if the request carries
+// none of the headers.
+func (n *Negotiator) Analyze(h http.Header) *api.NegotiationInfo {
+	info := &api.NegotiationInfo{}
+	found := false
+	for _, f := range n.fields {
+		values, ok := h[f.name]
+		if !ok {
+			continue
+		}
+		found = true
+		result := f.analyze(values)
+		switch f.name {
+		case Accept:
+			info.Accept = result
+		case AcceptLanguage:
+			info.AcceptLanguage = result
+		case AcceptEncoding:
+			info.AcceptEncoding = result
+		case AcceptCharset:
+			info.AcceptCharset = result
+		}
+	}
+	if !found {
+		return nil
+	}
+	return info
+}
+
+func (f *field) analyze(values []string) *api.NegotiationResult {
+	var entries []entry
+	var errs []string
+	for _, value := range values {
+		for _, elem := range splitOn(value, ',') {
+			elem = strings.TrimSpace(elem)
+			if elem == "" {
+				continue
+			}
+			e, err := f.parseEntry(elem)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("'%s': %v", elem, err))
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	result := &api.NegotiationResult{Entries: make([]api.NegotiationEntry, len(entries))}
+	for i, e := range entries {
+		result.Entries[i] = api.NegotiationEntry{Q: float32(e.q), Value: e.value}
+	}
+	if len(errs) > 0 {
+		result.Errors = &errs
+	}
+	if selected, ok := f.selectVariant(entries); ok {
+		result.Selected = &selected
+	}
+	return result
+}
+
+// parseEntry parses an element of the header with its weight: the q parameter ends the media
+// range parameters, and is the only parameter of the other headers.
+func (f *field) parseEntry(elem string) (entry, error) {
+	value, params := split(elem, ';')
+	e := entry{q: 1}
+	for i, p := range params {
+		key, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if !qvalue.MatchString(v) {
+			return e, fmt.Errorf("invalid q-value '%s', expected 0 to 1 with up to 3 decimals", v)
+		}
+		e.q, _ = strconv.ParseFloat(v, 64)
+		params = params[:i]
+		break
+	}
+	var err error
+	e.value, err = f.parse(strings.TrimSpace(value), params)
+	return e, err
+}
+
+// selectVariant returns the supported value with the highest q-value, the first one in the
+// order of preference of the server on ties. Values are weighted by the most specific element
+// matching them.
+func (f *field) selectVariant(entries []entry) (string, bool) {
+	best, bestQ := "", 0.0
+	for _, value := range f.supported {
+		q, specificity := 0.0, -1
+		if f.fallback != nil {
+			q = f.fallback(value)
+		}
+		for _, e := range entries {
+			if s := f.match(e.value, value); s > specificity {
+				q, specificity = e.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = value, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// parseMediaRange parses a media range (type/subtype, type/* or */*) with its parameters,
+// returning it in lower case but for the parameter values.
+func parseMediaRange(value string, params []string) (string, error) {
+	typ, subtype, ok := strings.Cut(strings.ToLower(value), "/")
+	if !ok || !token.MatchString(typ) || !token.MatchString(subtype) {
+		return "", fmt.Errorf("invalid media range, expected type/subtype")
+	}
+	if typ == "*" && subtype != "*" {
+		return "", fmt.Errorf("invalid media range, expected */* for a wildcard type")
+	}
+	rng := typ + "/" + subtype
+	for _, p := range params {
+		key, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || !token.MatchString(key) || v == "" {
+			return "", fmt.Errorf("invalid media range parameter '%s'", strings.TrimSpace(p))
+		}
+		rng += ";" + key + "=" + strings.TrimSpace(v)
+	}
+	return rng, nil
+}
+
+// matchMediaRange returns the specificity of the media range matching the media type, -1 if
+// it doesn't match: the parameters of the range must all be parameters of the type.
+func matchMediaRange(rng, value string) int {
+	rngType, rngParams := split(rng, ';')
+	valueType, valueParams := split(value, ';')
+	typ, subtype, _ := strings.Cut(rngType, "/")
+	switch {
+	case rngType == "*/*":
+		return 0
+	case subtype == "*":
+		if strings.HasPrefix(valueType, typ+"/") {
+			return 1
+		}
+		return -1
+	case rngType != valueType:
+		return -1
+	}
+	for _, p := range rngParams {
+		if !slices.Contains(valueParams, p) {
+			return -1
+		}
+	}
+	return 2 + len(rngParams)
+}
+
+// parseLanguageRange parses a basic language range (RFC 4647), as it was received.
+func parseLanguageRange(value string, params []string) (string, error) {
+	if len(params) > 0 {
+		return "", fmt.Errorf("unexpected parameter '%s'", strings.TrimSpace(params[0]))
+	}
+	if !languageRange.MatchString(value) {
+		return "", fmt.Errorf("invalid language range, expected subtags of up to 8 letters or digits (e.g. en-US)")
+	}
+	return value, nil
+}
+
+// matchLanguageRange returns the specificity of the language range matching the language tag
+// with the basic filtering of RFC 4647, -1 if it doesn't match: "de-CH" does not match "de".
+func matchLanguageRange(rng, value string) int {
+	rng, value = strings.ToLower(rng), strings.ToLower(value)
+	switch {
+	case rng == "*":
+		return 0
+	case rng == value || strings.HasPrefix(value, rng+"-"):
+		return len(rng)
+	}
+	return -1
+}
+
+// parseToken parses a content coding or a charset, returning it in lower case.
+func parseToken(value string, params []string) (string, error) {
+	if len(params) > 0 {
+		return "", fmt.Errorf("unexpected parameter '%s'", strings.TrimSpace(params[0]))
+	}
+	if !token.MatchString(value) {
+		return "", fmt.Errorf("invalid token")
+	}
+	return strings.ToLower(value), nil
+}
+
+// matchToken returns the specificity of the content coding or charset matching the value,
+// -1 if it doesn't match.
+func matchToken(rng, value string) int {
+	switch rng {
+	case "*":
+		return 0
+	case value:
+		return 1
+	}
+	return -1
+}
+
+// identity returns the q-value of the content codings not matched by any element.
+func identity(value string) float64 {
+	if value == Identity {
+		return 1
+	}
+	return 0
+}
+
+// split returns the first element of a list separated by sep, and the other ones.
+func split(s string, sep byte) (string, []string) {
+	parts := splitOn(s, sep)
+	return parts[0], parts[1:]
+}
+
+// splitOn splits a list separated by sep, skipping the separators within quoted strings.
+func splitOn(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
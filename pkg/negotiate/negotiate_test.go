@@ -0,0 +1,186 @@
+package negotiate
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "none", cfg: Config{}},
+		{name: "all", cfg: Config{Types: []string{"text/html", "application/json;charset=utf-8"}, Languages: []string{"en", "fr-CA"}, Encodings: []string{"br", "gzip"}, Charsets: []string{"utf-8"}}},
+		{name: "wildcard type", cfg: Config{Types: []string{"text/*"}}, wantErr: true},
+		{name: "missing subtype", cfg: Config{Types: []string{"html"}}, wantErr: true},
+		{name: "invalid language", cfg: Config{Languages: []string{"english_US"}}, wantErr: true},
+		{name: "wildcard language", cfg: Config{Languages: []string{"*"}}, wantErr: true},
+		{name: "invalid encoding", cfg: Config{Encodings: []string{"g zip"}}, wantErr: true},
+		{name: "weighted charset", cfg: Config{Charsets: []string{"utf-8;q=0.5"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	s := func(v string) *string { return &v }
+	cfg := Config{
+		Types:     []string{"text/html", "application/json"},
+		Languages: []string{"en", "fr-CA", "de"},
+		Encodings: []string{"br", "gzip"},
+		Charsets:  []string{"utf-8", "iso-8859-1"},
+	}
+	tests := []struct {
+		name    string
+		cfg     Config
+		headers http.Header
+		want    *api.NegotiationInfo
+	}{
+		{
+			name:    "no headers",
+			cfg:     cfg,
+			headers: http.Header{"User-Agent": {"curl/8.5.0"}},
+		},
+		{
+			name:    "without supported values",
+			headers: http.Header{"Accept-Language": {"fr-CA, fr;q=0.9, en;q=0.8"}},
+			want: &api.NegotiationInfo{AcceptLanguage: &api.NegotiationResult{Entries: []api.NegotiationEntry{
+				{Q: 1, Value: "fr-CA"}, {Q: 0.9, Value: "fr"}, {Q: 0.8, Value: "en"},
+			}}},
+		},
+		{
+			name: "browser request",
+			cfg:  cfg,
+			headers: http.Header{
+				"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+				"Accept-Language": {"en-US,en;q=0.5"},
+				"Accept-Encoding": {"gzip, deflate, br, zstd"},
+			},
+			want: &api.NegotiationInfo{
+				Accept: &api.NegotiationResult{
+					Entries: []api.NegotiationEntry{
+						{Q: 1, Value: "text/html"}, {Q: 1, Value: "application/xhtml+xml"},
+						{Q: 0.9, Value: "application/xml"}, {Q: 0.8, Value: "*/*"},
+					},
+					Selected: s("text/html"),
+				},
+				AcceptEncoding: &api.NegotiationResult{
+					Entries:  []api.NegotiationEntry{{Q: 1, Value: "gzip"}, {Q: 1, Value: "deflate"}, {Q: 1, Value: "br"}, {Q: 1, Value: "zstd"}},
+					Selected: s("br"),
+				},
+				AcceptLanguage: &api.NegotiationResult{
+					Entries:  []api.NegotiationEntry{{Q: 1, Value: "en-US"}, {Q: 0.5, Value: "en"}},
+					Selected: s("en"),
+				},
+			},
+		},
+		{
+			name: "most specific range wins",
+			cfg:  cfg,
+			headers: http.Header{
+				"Accept":          {"text/*;q=0.3, text/html;q=0.7, text/html;level=1, */*;q=0.5"},
+				"Accept-Language": {"fr, *;q=0.1"},
+			},
+			want: &api.NegotiationInfo{
+				Accept: &api.NegotiationResult{
+					Entries: []api.NegotiationEntry{
+						{Q: 1, Value: "text/html;level=1"}, {Q: 0.7, Value: "text/html"},
+						{Q: 0.5, Value: "*/*"}, {Q: 0.3, Value: "text/*"},
+					},
+					Selected: s("text/html"),
+				},
+				AcceptLanguage: &api.NegotiationResult{
+					Entries:  []api.NegotiationEntry{{Q: 1, Value: "fr"}, {Q: 0.1, Value: "*"}},
+					Selected: s("fr-CA"),
+				},
+			},
+		},
+		{
+			name: "exclusions",
+			cfg:  cfg,
+			headers: http.Header{
+				"Accept":          {"application/json;q=0, text/html;q=0"},
+				"Accept-Encoding": {"br;q=0, *;q=0.5"},
+				"Accept-Charset":  {"utf-16"},
+			},
+			want: &api.NegotiationInfo{
+				Accept: &api.NegotiationResult{Entries: []api.NegotiationEntry{
+					{Q: 0, Value: "application/json"}, {Q: 0, Value: "text/html"},
+				}},
+				AcceptCharset: &api.NegotiationResult{Entries: []api.NegotiationEntry{{Q: 1, Value: "utf-16"}}},
+				AcceptEncoding: &api.NegotiationResult{
+					Entries:  []api.NegotiationEntry{{Q: 0.5, Value: "*"}, {Q: 0, Value: "br"}},
+					Selected: s("gzip"),
+				},
+			},
+		},
+		{
+			name: "identity",
+			cfg:  cfg,
+			headers: http.Header{
+				"Accept-Encoding": {""},
+			},
+			want: &api.NegotiationInfo{AcceptEncoding: &api.NegotiationResult{
+				Entries:  []api.NegotiationEntry{},
+				Selected: s(Identity),
+			}},
+		},
+		{
+			name: "malformed entries",
+			cfg:  cfg,
+			headers: http.Header{
+				"Accept":          {`text/html;q=1.5, html, */json, text/plain;format="a,b", application/json;q=0.1`},
+				"Accept-Language": {"en_US, fr;q=0.5, de;x=1,,", "es;q=0.0001"},
+				"Accept-Charset":  {"UTF-8;q=0.8"},
+			},
+			want: &api.NegotiationInfo{
+				Accept: &api.NegotiationResult{
+					Entries: []api.NegotiationEntry{{Q: 1, Value: `text/plain;format="a,b"`}, {Q: 0.1, Value: "application/json"}},
+					Errors: &[]string{
+						"'text/html;q=1.5': invalid q-value '1.5', expected 0 to 1 with up to 3 decimals",
+						"'html': invalid media range, expected type/subtype",
+						"'*/json': invalid media range, expected */* for a wildcard type",
+					},
+					Selected: s("application/json"),
+				},
+				AcceptCharset: &api.NegotiationResult{
+					Entries:  []api.NegotiationEntry{{Q: 0.8, Value: "utf-8"}},
+					Selected: s("utf-8"),
+				},
+				AcceptLanguage: &api.NegotiationResult{
+					Entries: []api.NegotiationEntry{{Q: 0.5, Value: "fr"}},
+					Errors: &[]string{
+						"'en_US': invalid language range, expected subtags of up to 8 letters or digits (e.g. en-US)",
+						"'de;x=1': unexpected parameter 'x=1'",
+						"'es;q=0.0001': invalid q-value '0.0001', expected 0 to 1 with up to 3 decimals",
+					},
+					Selected: s("fr-CA"),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("New() unexpected error = %v", err)
+			}
+			if got := n.Analyze(tt.headers); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
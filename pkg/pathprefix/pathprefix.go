@@ -0,0 +1,10 @@
+package pathprefix
+
+import "strings"
+
+// Match checks if the prefix matches the path on a segment boundary: "/admin" matches
+// "/admin" and "/admin/users", but not "/administrator".
+func Match(path, prefix string) bool {
+	rest, ok := strings.CutPrefix(path, prefix)
+	return ok && (rest == "" || strings.HasSuffix(prefix, "/") || strings.HasPrefix(rest, "/"))
+}
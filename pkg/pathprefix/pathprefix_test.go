@@ -0,0 +1,28 @@
+package pathprefix
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		want   bool
+	}{
+		{path: "/admin", prefix: "/admin", want: true},
+		{path: "/admin/users", prefix: "/admin", want: true},
+		{path: "/administrator", prefix: "/admin", want: false},
+		{path: "/admin/users", prefix: "/admin/", want: true},
+		{path: "/admin", prefix: "/admin/", want: false},
+		{path: "/anything", prefix: "/", want: true},
+		{path: "/api", prefix: "/api", want: true},
+		{path: "/apiv2", prefix: "/api", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.prefix, func(t *testing.T) {
+			if got := Match(tt.path, tt.prefix); got != tt.want {
+				t.Fatalf("Match(%q, %q) = %v, want %v", tt.path, tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// Header names of the proxy chain.
+const (
+	Forwarded       = "Forwarded"
+	XForwardedFor   = "X-Forwarded-For"
+	XForwardedProto = "X-Forwarded-Proto"
+	XForwardedHost  = "X-Forwarded-Host"
+	XForwardedPort  = "X-Forwarded-Port"
+	XForwardedPfx   = "X-Forwarded-Prefix"
+	Via             = "Via"
+)
+
+// element is an element of the Forwarded header: a set of parameters, keyed in lower case.
+type element map[string]string
+
+// Chain parses the Forwarded (RFC 7239), X-Forwarded-* and Via headers into the list of the
+// proxy hops the request went through, from the closest to the client to the closest to the
+// server. The i-th hop merges the i-th entries of the headers, as each proxy appends its own.
+// Forwarded has precedence over X-Forwarded-*: the inconsistencies between the two header
+// families are returned. Returns nil if the request carries none of the headers.
+func Chain(h http.Header) ([]api.ProxyHop, []string) {
+	var issues []string
+
+	var forwarded []element
+	for _, raw := range splitList(strings.Join(h.Values(Forwarded), ",")) {
+		e, err := parseElement(raw)
+		if err != nil {
+			issues = append(issues, err.Error())
+			continue
+		}
+		forwarded = append(forwarded, e)
+	}
+	xff := splitList(strings.Join(h.Values(XForwardedFor), ","))
+	xfProto := splitList(strings.Join(h.Values(XForwardedProto), ","))
+	xfHost := splitList(strings.Join(h.Values(XForwardedHost), ","))
+	xfPort := splitList(strings.Join(h.Values(XForwardedPort), ","))
+	xfPrefix := splitList(strings.Join(h.Values(XForwardedPfx), ","))
+	via := splitList(strings.Join(h.Values(Via), ","))
+
+	n := max(len(forwarded), len(xff), len(xfProto), len(xfHost), len(xfPort), len(xfPrefix), len(via))
+	if n == 0 {
+		return nil, issues
+	}
+
+	if len(forwarded) > 0 && len(xff) > 0 && len(forwarded) != len(xff) {
+		issues = append(issues, fmt.Sprintf("hop count mismatch: %s %d, %s %d", Forwarded, len(forwarded), XForwardedFor, len(xff)))
+	}
+
+	hops := make([]api.ProxyHop, n)
+	for i := range hops {
+		hop := &hops[i]
+		var e element
+		if i < len(forwarded) {
+			e = forwarded[i]
+		}
+		hop.For = merge(i, "for", e, XForwardedFor, xff, nodeIP, &issues)
+		hop.Proto = merge(i, "proto", e, XForwardedProto, xfProto, strings.ToLower, &issues)
+		hop.Host = merge(i, "host", e, XForwardedHost, xfHost, strings.ToLower, &issues)
+		hop.By = value(e["by"])
+		hop.Port = value(at(xfPort, i))
+		hop.Prefix = value(at(xfPrefix, i))
+		if v := at(via, i); v != "" {
+			hop.Via = value(viaProxy(v))
+		}
+	}
+	return hops, issues
+}
+
+// merge returns the value of the Forwarded parameter of the hop, or the one of the X-Forwarded
+// header if missing, recording an issue if both are set and differ once normalized.
+func merge(i int, param string, e element, header string, values []string, normalize func(string) string, issues *[]string) *string {
+	fwd, xf := e[param], at(values, i)
+	if fwd != "" && xf != "" && normalize(fwd) != normalize(xf) {
+		*issues = append(*issues, fmt.Sprintf("hop %d: %s %s=%s, %s %s", i+1, Forwarded, param, fwd, header, xf))
+	}
+	if fwd != "" {
+		return &fwd
+	}
+	return value(xf)
+}
+
+func at(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+func value(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// nodeIP returns the IP address of a node identifier (e.g. "[2001:db8::1]:4711", "192.0.2.1:80"),
+// or the identifier as it is if not an IP address (e.g. "unknown", "_hidden").
+func nodeIP(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+	if ip := net.ParseIP(node); ip != nil {
+		return ip.String()
+	}
+	return strings.ToLower(node)
+}
+
+// viaProxy returns the protocol and the name of the proxy of a Via entry, without the comment.
+func viaProxy(entry string) string {
+	if i := strings.Index(entry, "("); i >= 0 {
+		entry = entry[:i]
+	}
+	return strings.Join(strings.Fields(entry), " ")
+}
+
+// splitList splits a comma separated header value, ignoring the commas in quoted strings and
+// comments, and dropping the empty entries.
+func splitList(s string) []string {
+	return split(s, ',')
+}
+
+// split splits the string on the separator, ignoring the separators in quoted strings and
+// comments, and dropping the empty entries.
+func split(s string, sep byte) []string {
+	var entries []string
+	var quoted bool
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && (quoted || depth > 0):
+			i++
+		case c == '"' && depth == 0:
+			quoted = !quoted
+		case c == '(' && !quoted:
+			depth++
+		case c == ')' && !quoted && depth > 0:
+			depth--
+		case c == sep && !quoted && depth == 0:
+			entries = appendEntry(entries, s[start:i])
+			start = i + 1
+		}
+	}
+	return appendEntry(entries, s[start:])
+}
+
+func appendEntry(entries []string, entry string) []string {
+	if entry = strings.TrimSpace(entry); entry != "" {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseElement parses a Forwarded element, e.g. `for="[2001:db8::1]:4711";proto=https`.
+func parseElement(raw string) (element, error) {
+	e := element{}
+	for _, pair := range split(raw, ';') {
+		name, v, ok := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid %s element '%s'", Forwarded, raw)
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) {
+			v = strings.ReplaceAll(v[1:len(v)-1], `\"`, `"`)
+		}
+		e[name] = v
+	}
+	return e, nil
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    http.Header
+		want       []api.ProxyHop
+		wantIssues []string
+	}{
+		{
+			name:    "no proxy headers",
+			headers: http.Header{"Accept": {"*/*"}},
+		},
+		{
+			name: "X-Forwarded headers",
+			headers: http.Header{
+				"X-Forwarded-For":    {"192.0.2.60, 10.0.0.1"},
+				"X-Forwarded-Proto":  {"https"},
+				"X-Forwarded-Host":   {"example.com"},
+				"X-Forwarded-Port":   {"443"},
+				"X-Forwarded-Prefix": {"/api"},
+			},
+			want: []api.ProxyHop{
+				{For: ptr("192.0.2.60"), Host: ptr("example.com"), Port: ptr("443"), Prefix: ptr("/api"), Proto: ptr("https")},
+				{For: ptr("10.0.0.1")},
+			},
+		},
+		{
+			name: "Forwarded and Via headers",
+			headers: http.Header{
+				"Forwarded": {`for="[2001:db8:cafe::17]:4711";proto=https;by=203.0.113.43`, `For=198.51.100.17;host="example.com"`},
+				"Via":       {"1.1 edge (Edge, v1.2), HTTP/1.1 ingress"},
+			},
+			want: []api.ProxyHop{
+				{By: ptr("203.0.113.43"), For: ptr("[2001:db8:cafe::17]:4711"), Proto: ptr("https"), Via: ptr("1.1 edge")},
+				{For: ptr("198.51.100.17"), Host: ptr("example.com"), Via: ptr("HTTP/1.1 ingress")},
+			},
+		},
+		{
+			name: "consistent header families",
+			headers: http.Header{
+				"Forwarded":         {`for="[2001:db8:cafe::17]:4711";proto=HTTPS`},
+				"X-Forwarded-For":   {"2001:db8:cafe::17"},
+				"X-Forwarded-Proto": {"https"},
+			},
+			want: []api.ProxyHop{
+				{For: ptr("[2001:db8:cafe::17]:4711"), Proto: ptr("HTTPS")},
+			},
+		},
+		{
+			name: "inconsistent header families",
+			headers: http.Header{
+				"Forwarded":         {"for=192.0.2.60;proto=http"},
+				"X-Forwarded-For":   {"198.51.100.17, 10.0.0.1"},
+				"X-Forwarded-Proto": {"https"},
+			},
+			want: []api.ProxyHop{
+				{For: ptr("192.0.2.60"), Proto: ptr("http")},
+				{For: ptr("10.0.0.1")},
+			},
+			wantIssues: []string{
+				"hop count mismatch: Forwarded 1, X-Forwarded-For 2",
+				"hop 1: Forwarded for=192.0.2.60, X-Forwarded-For 198.51.100.17",
+				"hop 1: Forwarded proto=http, X-Forwarded-Proto https",
+			},
+		},
+		{
+			name: "invalid Forwarded element",
+			headers: http.Header{
+				"Forwarded": {"garbage, for=192.0.2.60"},
+			},
+			want: []api.ProxyHop{
+				{For: ptr("192.0.2.60")},
+			},
+			wantIssues: []string{"invalid Forwarded element 'garbage'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, issues := Chain(tt.headers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Chain() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(issues, tt.wantIssues) {
+				t.Fatalf("Chain() issues = %q, want %q", issues, tt.wantIssues)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(`a, "b,c" ,, d (e, f), `)
+	want := []string{"a", `"b,c"`, "d (e, f)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitList() = %q, want %q", got, want)
+	}
+}
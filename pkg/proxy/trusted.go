@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// Methods resolving the client address: apart from MethodRemoteAddr, they are the lower case
+// names of the headers the address is taken from.
+const (
+	// MethodRemoteAddr takes the address of the TCP peer.
+	MethodRemoteAddr = "remote-addr"
+	// MethodXForwardedFor walks X-Forwarded-For from the right, skipping the trusted proxies.
+	MethodXForwardedFor = "x-forwarded-for"
+	// MethodForwarded walks the Forwarded for parameters from the right, skipping the trusted proxies.
+	MethodForwarded = "forwarded"
+	// MethodXRealIP takes the X-Real-Ip header set by a trusted proxy.
+	MethodXRealIP = "x-real-ip"
+	// MethodCFConnectingIP takes the CF-Connecting-IP header set by a trusted proxy.
+	MethodCFConnectingIP = "cf-connecting-ip"
+)
+
+// Presets are the named sets of proxy address ranges accepted by NewResolver.
+var Presets = map[string][]string{
+	// https://www.cloudflare.com/ips/
+	"cloudflare": {
+		"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+		"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+		"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+		"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+		"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+		"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+	},
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"rfc1918":  {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+}
+
+// Resolver resolves the client address of the requests, trusting the proxy headers only when
+// set by trusted proxies. A nil Resolver trusts no proxy.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver parses the trusted proxies: CIDRs, IP addresses or preset names.
+func NewResolver(specs []string) (*Resolver, error) {
+	res := &Resolver{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if ranges, ok := Presets[strings.ToLower(spec)]; ok {
+			for _, cidr := range ranges {
+				res.trusted = append(res.trusted, netip.MustParsePrefix(cidr))
+			}
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(spec); err == nil {
+			res.trusted = append(res.trusted, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(spec); err == nil {
+			res.trusted = append(res.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		presets := make([]string, 0, len(Presets))
+		for name := range Presets {
+			presets = append(presets, name)
+		}
+		slices.Sort(presets)
+		return nil, fmt.Errorf("invalid trusted proxy '%s', expected a CIDR, an IP address or one of: %s", spec, strings.Join(presets, ", "))
+	}
+	return res, nil
+}
+
+// Trusted reports whether the address belongs to a trusted proxy.
+func (res *Resolver) Trusted(addr netip.Addr) bool {
+	if res == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(res.trusted, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// Resolve returns the client address of the request. If the TCP peer is a trusted proxy, the
+// proxy headers are inspected in order: X-Forwarded-For and Forwarded are walked from the right,
+// skipping the trusted proxies, then X-Real-Ip and CF-Connecting-IP are taken as they are.
+// Otherwise, or if the headers carry no valid address, the address of the peer is returned.
+func (res *Resolver) Resolve(r *http.Request) api.RemoteInfo {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	info := api.RemoteInfo{Address: peer, Method: MethodRemoteAddr, Peer: peer}
+
+	peerAddr, err := netip.ParseAddr(peer)
+	if err != nil || !res.Trusted(peerAddr) {
+		return info
+	}
+	info.PeerTrusted = true
+
+	if xff := splitList(strings.Join(r.Header.Values(XForwardedFor), ",")); len(xff) > 0 {
+		if addr, ok := res.walk(xff); ok {
+			info.Address, info.Method = addr, MethodXForwardedFor
+		}
+		return info
+	}
+
+	var nodes []string
+	for _, raw := range splitList(strings.Join(r.Header.Values(Forwarded), ",")) {
+		if e, err := parseElement(raw); err == nil && e["for"] != "" {
+			nodes = append(nodes, e["for"])
+		}
+	}
+	if len(nodes) > 0 {
+		if addr, ok := res.walk(nodes); ok {
+			info.Address, info.Method = addr, MethodForwarded
+		}
+		return info
+	}
+
+	for _, method := range []string{MethodXRealIP, MethodCFConnectingIP} {
+		if addr, err := netip.ParseAddr(nodeIP(strings.TrimSpace(r.Header.Get(method)))); err == nil {
+			info.Address, info.Method = addr.Unmap().String(), method
+			return info
+		}
+	}
+	return info
+}
+
+// walk returns the client address of a list of nodes appended by proxies: the rightmost one
+// that is not a trusted proxy, or the leftmost one if all of them are trusted. The walk stops
+// at the first invalid address (e.g. "unknown"), returning the last valid one.
+func (res *Resolver) walk(nodes []string) (string, bool) {
+	client := ""
+	for i := len(nodes) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(nodeIP(nodes[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap().String()
+		if !res.Trusted(addr) {
+			break
+		}
+	}
+	return client, client != ""
+}
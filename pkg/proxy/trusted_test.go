@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+func TestNewResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		wantErr bool
+	}{
+		{name: "presets", specs: []string{"cloudflare", "RFC1918", "loopback"}},
+		{name: "CIDRs and addresses", specs: []string{"203.0.113.0/24", "2001:db8::/32", "198.51.100.7", "::1"}},
+		{name: "invalid", specs: []string{"10.0.0.0/8", "proxy.example.com"}, wantErr: true},
+		{name: "invalid CIDR", specs: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewResolver(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewResolver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	resolver, err := NewResolver([]string{"rfc1918", "cloudflare", "::1"})
+	if err != nil {
+		t.Fatalf("NewResolver() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		resolver   *Resolver
+		remoteAddr string
+		headers    http.Header
+		want       api.RemoteInfo
+	}{
+		{
+			name:       "no trusted proxies",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    http.Header{"X-Forwarded-For": {"192.0.2.60"}},
+			want:       api.RemoteInfo{Address: "10.0.0.1", Method: MethodRemoteAddr, Peer: "10.0.0.1"},
+		},
+		{
+			name:       "untrusted peer",
+			resolver:   resolver,
+			remoteAddr: "192.0.2.99:5000",
+			headers:    http.Header{"X-Forwarded-For": {"192.0.2.60"}},
+			want:       api.RemoteInfo{Address: "192.0.2.99", Method: MethodRemoteAddr, Peer: "192.0.2.99"},
+		},
+		{
+			name:       "X-Forwarded-For walked from the right",
+			resolver:   resolver,
+			remoteAddr: "10.0.0.1:5000",
+			headers:    http.Header{"X-Forwarded-For": {"203.0.113.9, 192.0.2.60", "162.158.1.1, 10.0.0.2"}},
+			want:       api.RemoteInfo{Address: "192.0.2.60", Method: MethodXForwardedFor, Peer: "10.0.0.1", PeerTrusted: true},
+		},
+		{
+			name:       "all proxies trusted",
+			resolver:   resolver,
+			remoteAddr: "[::1]:5000",
+			headers:    http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:       api.RemoteInfo{Address: "10.0.0.3", Method: MethodXForwardedFor, Peer: "::1", PeerTrusted: true},
+		},
+		{
+			name:       "invalid X-Forwarded-For entry",
+			resolver:   resolver,
+			remoteAddr: "10.0.0.1:5000",
+			headers:    http.Header{"X-Forwarded-For": {"192.0.2.60, unknown, 10.0.0.2"}},
+			want:       api.RemoteInfo{Address: "10.0.0.2", Method: MethodXForwardedFor, Peer: "10.0.0.1", PeerTrusted: true},
+		},
+		{
+			name:       "Forwarded",
+			resolver:   resolver,
+			remoteAddr: "10.0.0.1:5000",
+			headers:    http.Header{"Forwarded": {`for="[2001:db8:cafe::17]:4711", for=10.0.0.2`}},
+			want:       api.RemoteInfo{Address: "2001:db8:cafe::17", Method: MethodForwarded, Peer: "10.0.0.1", PeerTrusted: true},
+		},
+		{
+			name:       "X-Real-Ip",
+			resolver:   resolver,
+			remoteAddr: "10.0.0.1:5000",
+			headers:    http.Header{"X-Real-Ip": {"192.0.2.60"}, "Cf-Connecting-Ip": {"192.0.2.61"}},
+			want:       api.RemoteInfo{Address: "192.0.2.60", Method: MethodXRealIP, Peer: "10.0.0.1", PeerTrusted: true},
+		},
+		{
+			name:       "CF-Connecting-IP",
+			resolver:   resolver,
+			remoteAddr: "162.158.1.1:5000",
+			headers:    http.Header{"Cf-Connecting-Ip": {"192.0.2.61"}},
+			want:       api.RemoteInfo{Address: "192.0.2.61", Method: MethodCFConnectingIP, Peer: "162.158.1.1", PeerTrusted: true},
+		},
+		{
+			name:       "trusted peer without proxy headers",
+			resolver:   resolver,
+			remoteAddr: "10.0.0.1:5000",
+			want:       api.RemoteInfo{Address: "10.0.0.1", Method: MethodRemoteAddr, Peer: "10.0.0.1", PeerTrusted: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+			if got := tt.resolver.Resolve(req); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package render
+
+import (
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// cborEncMode encodes map keys in the CBOR core deterministic order, for stable output.
+var cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// renderCBOR writes the echo payload as CBOR (RFC 8949). Struct fields are encoded as maps
+// keyed by their JSON names.
+func renderCBOR(w io.Writer, e *Echo) error {
+	return cborEncMode.NewEncoder(w).Encode(e.Response)
+}
+
+// renderMsgPack writes the echo payload as MessagePack. Struct fields are encoded as maps
+// keyed by their JSON names.
+func renderMsgPack(w io.Writer, e *Echo) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	return enc.Encode(e.Response)
+}
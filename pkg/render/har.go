@@ -0,0 +1,219 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HAROptions are the server settings of the HAR output.
+type HAROptions struct {
+	// Version is the headertrace version, reported as the creator of the HAR logs.
+	Version string
+}
+
+// harRedacted replaces the values of the redacted headers in the HAR logs.
+const harRedacted = "[redacted]"
+
+// HAR 1.2 types, see http://www.softwareishard.com/blog/har-12-spec/.
+
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+}
+
+type harRequest struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	HTTPVersion string    `json:"httpVersion"`
+	Cookies     []harPair `json:"cookies"`
+	Headers     []harPair `json:"headers"`
+	QueryString []harPair `json:"queryString"`
+	HeadersSize int       `json:"headersSize"`
+	BodySize    int64     `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int       `json:"status"`
+	StatusText  string    `json:"statusText"`
+	HTTPVersion string    `json:"httpVersion"`
+	Cookies     []harPair `json:"cookies"`
+	Headers     []harPair `json:"headers"`
+	Content     harBody   `json:"content"`
+	RedirectURL string    `json:"redirectURL"`
+	HeadersSize int       `json:"headersSize"`
+	BodySize    int       `json:"bodySize"`
+	Comment     string    `json:"comment,omitempty"`
+}
+
+type harBody struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// renderHAR writes the exchange as a HAR 1.2 log with a single entry. The timings are the ones
+// measured by the server: the wait time is the processing time of the request, the others are
+// unknown to the server. The response body is the HAR log itself, so its size is not reported.
+func renderHAR(w io.Writer, e *Echo) error {
+	r := e.Request
+	isRedacted := func(name string) bool {
+		return slices.ContainsFunc(e.Redacted, func(h string) bool { return strings.EqualFold(h, name) })
+	}
+
+	req := harRequest{
+		Method:      r.Method,
+		URL:         requestURL(r),
+		HTTPVersion: r.Proto,
+		Cookies:     []harPair{},
+		Headers:     []harPair{},
+		QueryString: []harPair{},
+		HeadersSize: -1,
+		BodySize:    max(r.ContentLength, 0),
+	}
+	if e.Raw != nil {
+		req.HeadersSize = len(e.Raw)
+	}
+	for _, f := range headerFields(e) {
+		if isRedacted(f.name) {
+			f.value = harRedacted
+		}
+		req.Headers = append(req.Headers, harPair{Name: f.name, Value: f.value})
+	}
+	if !isRedacted("Cookie") {
+		for _, c := range r.Cookies() {
+			req.Cookies = append(req.Cookies, harPair{Name: c.Name, Value: c.Value})
+		}
+	}
+	for _, pair := range strings.Split(r.URL.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		req.QueryString = append(req.QueryString, harPair{Name: queryUnescape(name), Value: queryUnescape(value)})
+	}
+
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := harResponse{
+		Status:      status,
+		StatusText:  http.StatusText(status),
+		HTTPVersion: r.Proto,
+		Cookies:     []harPair{},
+		Headers:     []harPair{},
+		Content:     harBody{MimeType: e.ResponseHeaders.Get("Content-Type")},
+		HeadersSize: -1,
+		BodySize:    -1,
+		Comment:     "The response body is this HAR log.",
+	}
+	keys := make([]string, 0, len(e.ResponseHeaders))
+	for key := range e.ResponseHeaders {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		for _, value := range e.ResponseHeaders[key] {
+			resp.Headers = append(resp.Headers, harPair{Name: key, Value: value})
+		}
+	}
+
+	received := e.Received
+	if received.IsZero() {
+		received = time.Now()
+	}
+	wait := float64(time.Since(received).Microseconds()) / 1000
+	entry := harEntry{
+		StartedDateTime: received.Format(time.RFC3339Nano),
+		Time:            wait,
+		Request:         req,
+		Response:        resp,
+		Timings:         harTimings{Blocked: -1, DNS: -1, Connect: -1, Wait: wait, SSL: -1},
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			entry.ServerIPAddress = host
+		}
+	}
+	// The client port identifies the TCP connection.
+	if _, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		entry.Connection = port
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "headertrace", Version: e.HAR.Version},
+		Entries: []harEntry{entry},
+	}})
+}
+
+// requestURL returns the absolute URL of the request.
+func requestURL(r *http.Request) string {
+	u := absoluteURL(r)
+	return u.String()
+}
+
+// absoluteURL returns the URL of the request with the scheme and host it was received on.
+func absoluteURL(r *http.Request) url.URL {
+	u := *r.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	return u
+}
+
+func queryUnescape(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+//go:embed templates/echo.html.tmpl
+var templatesFS embed.FS
+
+var htmlTemplate = template.Must(template.ParseFS(templatesFS, "templates/echo.html.tmpl"))
+
+// htmlFields are the HeaderResponse fields rendered in dedicated tables: all the other
+// fields are rendered as JSON sections.
+var htmlFields = []string{"headers", "host", "method", "path", "protocol", "sent"}
+
+type htmlHeader struct {
+	Name  string
+	Value string
+	Class string
+}
+
+type htmlSection struct {
+	Name string
+	JSON string
+}
+
+type htmlPage struct {
+	Response        api.HeaderResponse
+	RequestHeaders  []htmlHeader
+	ResponseHeaders []htmlHeader
+	Sections        []htmlSection
+}
+
+func renderHTML(w io.Writer, e *Echo) error {
+	page := htmlPage{Response: e.Response}
+
+	for name, value := range e.Response.Headers {
+		page.RequestHeaders = append(page.RequestHeaders, htmlHeader{Name: name, Value: value})
+	}
+	for _, name := range e.Redacted {
+		page.RequestHeaders = append(page.RequestHeaders, htmlHeader{Name: name, Value: "redacted", Class: "redacted"})
+	}
+	for name, values := range e.ResponseHeaders {
+		h := htmlHeader{Name: name, Value: strings.Join(values, ",")}
+		if slices.Contains(e.Added, name) {
+			h.Class = "added"
+		}
+		page.ResponseHeaders = append(page.ResponseHeaders, h)
+	}
+	byName := func(a, b htmlHeader) int { return strings.Compare(a.Name, b.Name) }
+	slices.SortFunc(page.RequestHeaders, byName)
+	slices.SortFunc(page.ResponseHeaders, byName)
+
+	sections, err := extraSections(e.Response)
+	if err != nil {
+		return err
+	}
+	page.Sections = sections
+
+	return htmlTemplate.Execute(w, page)
+}
+
+// extraSections returns the optional HeaderResponse fields as indented JSON sections.
+func extraSections(response api.HeaderResponse) ([]htmlSection, error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var sections []htmlSection
+	for name, raw := range fields {
+		if slices.Contains(htmlFields, name) {
+			continue
+		}
+		indented, err := json.MarshalIndent(raw, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, htmlSection{Name: name, JSON: string(indented)})
+	}
+	slices.SortFunc(sections, func(a, b htmlSection) int { return strings.Compare(a.Name, b.Name) })
+	return sections, nil
+}
@@ -0,0 +1,176 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+const (
+	// PrettyParam is the query parameter enabling or disabling the indentation of the JSON output.
+	PrettyParam = "pretty"
+	// OrderParam is the query parameter selecting the order of the echoed headers.
+	OrderParam = "order"
+	// CallbackParam is the query parameter holding the JSONP callback name.
+	CallbackParam = "callback"
+)
+
+const (
+	// OrderName sorts the echoed headers by name.
+	OrderName = "name"
+	// OrderReceived lists the echoed headers in the order they were received.
+	OrderReceived = "received"
+)
+
+// defaultCallback is the JSONP callback name used when none is provided.
+const defaultCallback = "callback"
+
+// maxCallbackLength is the maximum length of the JSONP callback names.
+const maxCallbackLength = 128
+
+// callbackRegexp matches the valid JSONP callback names: JavaScript identifiers, possibly
+// separated by dots (e.g. "widget.onEcho").
+var callbackRegexp = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)*$`)
+
+// JSONOptions are the server defaults of the JSON output, overridden by the query parameters.
+type JSONOptions struct {
+	// Compact disables the indentation of the JSON output.
+	Compact bool
+	// Order is the order of the echoed headers: OrderName or OrderReceived.
+	Order string
+}
+
+// CheckOrder validates the order of the echoed headers.
+func CheckOrder(order string) error {
+	if order != OrderName && order != OrderReceived {
+		return fmt.Errorf("invalid header order '%s', expected '%s' or '%s'", order, OrderName, OrderReceived)
+	}
+	return nil
+}
+
+// jsonOptions returns the JSON options of the echo, applying the query parameters overrides.
+func jsonOptions(e *Echo) JSONOptions {
+	opts := e.JSON
+	query := e.Request.URL.Query()
+	if v := query.Get(PrettyParam); v != "" {
+		if pretty, err := strconv.ParseBool(v); err == nil {
+			opts.Compact = !pretty
+		}
+	}
+	if v := query.Get(OrderParam); CheckOrder(v) == nil {
+		opts.Order = v
+	}
+	return opts
+}
+
+// marshalResponse returns the compact JSON encoding of the echo payload, with the headers in
+// the requested order. Other objects have their keys sorted.
+func marshalResponse(e *Echo, order string) ([]byte, error) {
+	if order != OrderReceived {
+		return json.Marshal(e.Response)
+	}
+
+	// The top level keys are sorted when marshaling a map, as the fields of HeaderResponse.
+	data, err := json.Marshal(e.Response)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, f := range headerFields(e) {
+		key := http.CanonicalHeaderKey(f.name)
+		if _, ok := e.Response.Headers[key]; ok && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	var missing []string
+	for key := range e.Response.Headers {
+		if !slices.Contains(keys, key) {
+			missing = append(missing, key)
+		}
+	}
+	slices.Sort(missing)
+	keys = append(keys, missing...)
+
+	var headers bytes.Buffer
+	headers.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			headers.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(e.Response.Headers[key])
+		if err != nil {
+			return nil, err
+		}
+		headers.Write(k)
+		headers.WriteByte(':')
+		headers.Write(v)
+	}
+	headers.WriteByte('}')
+	fields["headers"] = headers.Bytes()
+	return json.Marshal(fields)
+}
+
+// encodeJSON returns the JSON encoding of the echo payload, according to the JSON options.
+func encodeJSON(e *Echo) ([]byte, error) {
+	opts := jsonOptions(e)
+	data, err := marshalResponse(e, opts.Order)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Compact {
+		return data, nil
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, "", "  "); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func renderJSON(w io.Writer, e *Echo) error {
+	data, err := encodeJSON(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// renderJSONP wraps the JSON output in a call to the callback function. The leading comment
+// prevents the body from being interpreted as a Flash file (Rosetta Flash).
+func renderJSONP(w io.Writer, e *Echo) error {
+	data, err := encodeJSON(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "/**/%s(%s);\n", jsonpCallback(e.Request), data)
+	return err
+}
+
+// jsonpCallback returns the valid JSONP callback name requested, or the default one.
+func jsonpCallback(r *http.Request) string {
+	if name := r.URL.Query().Get(CallbackParam); validCallback(name) {
+		return name
+	}
+	return defaultCallback
+}
+
+// validCallback checks if the JSONP callback name is made of JavaScript identifiers separated
+// by dots, and is not longer than maxCallbackLength.
+func validCallback(name string) bool {
+	return len(name) <= maxCallbackLength && callbackRegexp.MatchString(name)
+}
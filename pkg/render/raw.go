@@ -0,0 +1,88 @@
+package render
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HexDumpParam is the query parameter adding the hex dump of the header bytes to the raw format.
+const HexDumpParam = "hexdump"
+
+// redactedValue replaces the values of the redacted headers in the raw format.
+const redactedValue = "[redacted]"
+
+// renderRaw prints the request header block the way it was received on the wire,
+// optionally followed by the hex dump of its bytes.
+func renderRaw(w io.Writer, e *Echo) error {
+	header := e.Raw
+	if header == nil {
+		header = rebuildHeader(e)
+	}
+	header = redactHeader(header, e.Redacted)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if dump, _ := strconv.ParseBool(e.Request.URL.Query().Get(HexDumpParam)); dump {
+		if _, err := fmt.Fprintf(w, "--- hex dump (%d bytes) ---\n%s", len(header), hex.Dump(header)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildHeader serializes the parsed request headers, for requests whose raw bytes
+// were not recorded (e.g. HTTP/2 requests).
+func rebuildHeader(e *Echo) []byte {
+	var b bytes.Buffer
+	r := e.Request
+	fmt.Fprintf(&b, "%s %s %s\r\n", r.Method, r.RequestURI, e.Response.Protocol)
+	fmt.Fprintf(&b, "Host: %s\r\n", r.Host)
+	keys := make([]string, 0, len(r.Header))
+	for key := range r.Header {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		for _, value := range r.Header[key] {
+			fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+		}
+	}
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// redactHeader replaces the values of the redacted header fields, dropping their obsolete
+// line folding continuations, and preserves all the other bytes.
+func redactHeader(header []byte, redacted []string) []byte {
+	if len(redacted) == 0 {
+		return header
+	}
+	var b bytes.Buffer
+	folded := false
+	for i, line := range bytes.SplitAfter(header, []byte("\n")) {
+		if i > 0 && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			if folded {
+				continue
+			}
+			b.Write(line)
+			continue
+		}
+		folded = false
+		if name, _, ok := bytes.Cut(line, []byte(":")); ok && i > 0 {
+			if slices.ContainsFunc(redacted, func(h string) bool { return strings.EqualFold(h, string(name)) }) {
+				eol := line[len(bytes.TrimRight(line, "\r\n")):]
+				fmt.Fprintf(&b, "%s: %s%s", name, redactedValue, eol)
+				folded = true
+				continue
+			}
+		}
+		b.Write(line)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,214 @@
+package render
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/logging"
+)
+
+// FormatParam is the query parameter selecting the output format, overriding the Accept header.
+const FormatParam = "format"
+
+// Echo holds the data rendered in the response body.
+type Echo struct {
+	// Response is the echo payload, as defined in the OpenAPI spec.
+	Response api.HeaderResponse
+	// Redacted lists the request headers redacted from the echoed headers.
+	Redacted []string
+	// Added lists the custom headers added to the response.
+	Added []string
+	// ResponseHeaders are the headers sent in the response.
+	ResponseHeaders http.Header
+	// Request is the received HTTP request.
+	Request *http.Request
+	// Raw is the request header block as received on the wire, if recorded.
+	Raw []byte
+	// Body is the request body, up to the size read by the server.
+	Body []byte
+	// BodyTruncated reports whether Body holds only part of the request body, as it is too
+	// long or could not be read in full.
+	BodyTruncated bool
+	// Status is the status code of the response.
+	Status int
+	// Received is the time the request was received.
+	Received time.Time
+	// JSON are the server defaults of the JSON output.
+	JSON JSONOptions
+	// HAR are the server settings of the HAR output.
+	HAR HAROptions
+}
+
+// Format is an output format of the echo response.
+type Format struct {
+	// Name is the value of the format query parameter selecting the format.
+	Name string
+	// MediaTypes are the media types selecting the format in the Accept header:
+	// the first one is used as the response Content-Type.
+	MediaTypes []string
+	render     func(w io.Writer, e *Echo) error
+}
+
+// ContentType returns the Content-Type of the responses in the format.
+func (f *Format) ContentType() string {
+	ct := f.MediaTypes[0]
+	if strings.HasPrefix(ct, "text/") {
+		ct += "; charset=utf-8"
+	}
+	return ct
+}
+
+// Render writes the echo in the format.
+func (f *Format) Render(w io.Writer, e *Echo) error {
+	return f.render(w, e)
+}
+
+// JSON is the default output format.
+var JSON = &Format{Name: "json", MediaTypes: []string{"application/json"}, render: renderJSON}
+
+// JSONP wraps the JSON output in a JavaScript callback, for legacy browser clients.
+var JSONP = &Format{Name: "jsonp", MediaTypes: []string{"application/javascript", "text/javascript"}, render: renderJSONP}
+
+// HTML renders a web page for browsers.
+var HTML = &Format{Name: "html", MediaTypes: []string{"text/html", "application/xhtml+xml"}, render: renderHTML}
+
+// Raw prints the request as it was received on the wire.
+var Raw = &Format{Name: "raw", MediaTypes: []string{"text/plain"}, render: renderRaw}
+
+// YAML renders the echo payload as YAML.
+var YAML = &Format{Name: "yaml", MediaTypes: []string{"application/yaml", "application/x-yaml", "text/yaml"}, render: renderYAML}
+
+// XML renders the echo payload as XML.
+var XML = &Format{Name: "xml", MediaTypes: []string{"application/xml", "text/xml"}, render: renderXML}
+
+// CBOR encodes the echo payload as CBOR, for constrained clients.
+var CBOR = &Format{Name: "cbor", MediaTypes: []string{"application/cbor"}, render: renderCBOR}
+
+// MsgPack encodes the echo payload as MessagePack.
+var MsgPack = &Format{Name: "msgpack", MediaTypes: []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, render: renderMsgPack}
+
+// Table renders the echo as aligned tables, for terminals.
+var Table = &Format{Name: "table", MediaTypes: []string{"text/plain"}, render: renderTable}
+
+// HAR renders the exchange as a HAR 1.2 log.
+var HAR = &Format{Name: "har", MediaTypes: []string{"application/har+json"}, render: renderHAR}
+
+// Snippets prints client snippets reproducing the request, in all the supported languages.
+// The snippet formats are listed after Raw, so are selected only with the format parameter.
+var Snippets = &Format{Name: "snippets", MediaTypes: []string{"text/plain"}, render: snippetRenderer("")}
+
+// Curl prints a curl command reproducing the request.
+var Curl = &Format{Name: "curl", MediaTypes: []string{"text/plain"}, render: snippetRenderer("curl")}
+
+// HTTPie prints an HTTPie command reproducing the request.
+var HTTPie = &Format{Name: "httpie", MediaTypes: []string{"text/plain"}, render: snippetRenderer("httpie")}
+
+// Go prints a Go net/http program reproducing the request.
+var Go = &Format{Name: "go", MediaTypes: []string{"text/plain"}, render: snippetRenderer("go")}
+
+// Python prints a Python requests script reproducing the request.
+var Python = &Format{Name: "python", MediaTypes: []string{"text/plain"}, render: snippetRenderer("python")}
+
+// formats lists the supported output formats: the first one is the default.
+var formats = []*Format{JSON, JSONP, HTML, Raw, YAML, XML, CBOR, MsgPack, Table, HAR, Snippets, Curl, HTTPie, Go, Python}
+
+// NegotiateOptions are the server settings of the output format negotiation.
+type NegotiateOptions struct {
+	// TerminalTable selects the table format for command line clients accepting any media type.
+	TerminalTable bool
+	// JSONP enables the JSONP format. Any web page can load it as a script, reading the echoed
+	// headers (cookies included) regardless of the CORS policy.
+	JSONP bool
+}
+
+// Negotiate selects the output format of the response: the format query parameter has
+// precedence, then the most preferred media type of the Accept header is selected.
+// Command line clients accepting any media type get the table format, if enabled.
+// Falls back to JSON when no supported format is requested. If JSONP is enabled, JSON is
+// wrapped in JSONP when a valid callback is requested.
+func Negotiate(r *http.Request, opts NegotiateOptions) *Format {
+	f := negotiate(r, opts)
+	if f == JSON && opts.JSONP && r.URL.Query().Has(CallbackParam) {
+		if name := r.URL.Query().Get(CallbackParam); !validCallback(name) {
+			logging.Debugf("Invalid JSONP callback '%s' requested, rendering JSON", name)
+			return JSON
+		}
+		return JSONP
+	}
+	return f
+}
+
+func negotiate(r *http.Request, opts NegotiateOptions) *Format {
+	enabled := func(f *Format) bool { return f != JSONP || opts.JSONP }
+	if name := r.URL.Query().Get(FormatParam); name != "" {
+		for _, f := range formats {
+			if strings.EqualFold(f.Name, name) && enabled(f) {
+				return f
+			}
+		}
+		logging.Debugf("Unsupported format '%s' requested, negotiating the Accept header", name)
+	}
+
+	if opts.TerminalTable && !(opts.JSONP && r.URL.Query().Has(CallbackParam)) && isTerminalClient(r) {
+		return Table
+	}
+
+	for _, mediaType := range parseAccept(r.Header.Values("Accept")) {
+		for _, f := range formats {
+			if slices.Contains(f.MediaTypes, mediaType) && enabled(f) {
+				return f
+			}
+		}
+	}
+	return formats[0]
+}
+
+// parseAccept returns the media types of the Accept header, sorted by decreasing preference.
+// Media types with q=0 are excluded, wildcards are returned as they are.
+func parseAccept(values []string) []string {
+	type entry struct {
+		mediaType string
+		q         float64
+	}
+	var entries []entry
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			params := strings.Split(item, ";")
+			e := entry{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+			if e.mediaType == "" {
+				continue
+			}
+			for _, p := range params[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(key, "q") {
+					if q, err := strconv.ParseFloat(value, 64); err == nil {
+						e.q = q
+					}
+				}
+			}
+			if e.q > 0 {
+				entries = append(entries, e)
+			}
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	mediaTypes := make([]string, len(entries))
+	for i, e := range entries {
+		mediaTypes[i] = e.mediaType
+	}
+	return mediaTypes
+}
@@ -0,0 +1,761 @@
+package render
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		accept    []string
+		userAgent string
+		noTable   bool
+		jsonp     bool
+		want      *Format
+	}{
+		{
+			name: "no Accept header",
+			url:  "/",
+			want: JSON,
+		},
+		{
+			name:   "any media type",
+			url:    "/",
+			accept: []string{"*/*"},
+			want:   JSON,
+		},
+		{
+			name:      "curl",
+			url:       "/",
+			accept:    []string{"*/*"},
+			userAgent: "curl/8.5.0",
+			want:      Table,
+		},
+		{
+			name:      "HTTPie",
+			url:       "/",
+			accept:    []string{"*/*"},
+			userAgent: "HTTPie/3.2.2",
+			want:      Table,
+		},
+		{
+			name:      "curl with terminal tables disabled",
+			url:       "/",
+			accept:    []string{"*/*"},
+			userAgent: "curl/8.5.0",
+			noTable:   true,
+			want:      JSON,
+		},
+		{
+			name:      "curl requesting JSON",
+			url:       "/",
+			accept:    []string{"application/json"},
+			userAgent: "curl/8.5.0",
+			want:      JSON,
+		},
+		{
+			name:      "curl with format parameter",
+			url:       "/?format=json",
+			accept:    []string{"*/*"},
+			userAgent: "curl/8.5.0",
+			want:      JSON,
+		},
+		{
+			name:      "wget with JSONP callback",
+			url:       "/?callback=cb",
+			accept:    []string{"*/*"},
+			userAgent: "Wget/1.21.3",
+			jsonp:     true,
+			want:      JSONP,
+		},
+		{
+			name:      "JSONP callback with JSONP disabled",
+			url:       "/?callback=cb",
+			accept:    []string{"*/*"},
+			userAgent: "Wget/1.21.3",
+			want:      Table,
+		},
+		{
+			name:   "JSONP format with JSONP disabled",
+			url:    "/?format=jsonp",
+			accept: []string{"application/javascript"},
+			want:   JSON,
+		},
+		{
+			name:   "JSONP media type",
+			url:    "/",
+			accept: []string{"application/javascript"},
+			jsonp:  true,
+			want:   JSONP,
+		},
+		{
+			name:  "invalid JSONP callback",
+			url:   "/?callback=alert(1)",
+			jsonp: true,
+			want:  JSON,
+		},
+		{
+			name:  "JSONP callback too long",
+			url:   "/?callback=" + strings.Repeat("a", maxCallbackLength+1),
+			jsonp: true,
+			want:  JSON,
+		},
+		{
+			name:   "browser",
+			url:    "/",
+			accept: []string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			want:   HTML,
+		},
+		{
+			name:   "JSON preferred over HTML",
+			url:    "/",
+			accept: []string{"text/html;q=0.5, application/json"},
+			want:   JSON,
+		},
+		{
+			name:   "HTML refused",
+			url:    "/",
+			accept: []string{"text/html;q=0, */*"},
+			want:   JSON,
+		},
+		{
+			name:   "unsupported media type",
+			url:    "/",
+			accept: []string{"image/png"},
+			want:   JSON,
+		},
+		{
+			name:   "YAML",
+			url:    "/",
+			accept: []string{"application/x-yaml"},
+			want:   YAML,
+		},
+		{
+			name:   "XML",
+			url:    "/",
+			accept: []string{"text/xml"},
+			want:   XML,
+		},
+		{
+			name:   "format parameter overrides Accept",
+			url:    "/?format=html",
+			accept: []string{"application/json"},
+			want:   HTML,
+		},
+		{
+			name:   "unknown format parameter",
+			url:    "/?format=unknown",
+			accept: []string{"text/html"},
+			want:   HTML,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for _, a := range tt.accept {
+				req.Header.Add("Accept", a)
+			}
+			if tt.userAgent != "" {
+				req.Header.Set("User-Agent", tt.userAgent)
+			}
+			opts := NegotiateOptions{TerminalTable: !tt.noTable, JSONP: tt.jsonp}
+			if got := Negotiate(req, opts); got != tt.want {
+				t.Fatalf("Negotiate() = %s, want %s", got.Name, tt.want.Name)
+			}
+		})
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	got := parseAccept([]string{"text/html;q=0.8, application/json", "text/plain;q=0, */*;q=0.1"})
+	want := []string{"application/json", "text/html", "*/*"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseAccept() = %v, want %v", got, want)
+	}
+}
+
+func testEcho() *Echo {
+	authInfo := &api.AuthInfo{Prefix: "/admin", Realm: "test", Reason: "missing Authorization header", Schemes: []string{"basic"}}
+	return &Echo{
+		Response: api.HeaderResponse{
+			Auth:     authInfo,
+			Headers:  map[string]string{"Accept": "*/*", "X-Script": "<script>"},
+			Host:     "example.com",
+			Method:   http.MethodGet,
+			Path:     "/admin",
+			Protocol: "HTTP/1.1",
+		},
+		Redacted:        []string{"Cookie"},
+		Added:           []string{"X-Served-By"},
+		ResponseHeaders: http.Header{"Content-Type": {"text/html"}, "X-Served-By": {"headertrace"}},
+		Request:         httptest.NewRequest(http.MethodGet, "/admin", nil),
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	var b bytes.Buffer
+	e := testEcho()
+	if err := JSON.Render(&b, e); err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+	var got api.HeaderResponse
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("Render() produced invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, e.Response) {
+		t.Fatalf("Render() = %+v, want %+v", got, e.Response)
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	var b bytes.Buffer
+	if err := YAML.Render(&b, testEcho()); err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+	want := `auth:
+  authenticated: false
+  prefix: /admin
+  realm: test
+  reason: missing Authorization header
+  schemes:
+    - basic
+headers:
+  Accept: '*/*'
+  X-Script: <script>
+host: example.com
+method: GET
+path: /admin
+protocol: HTTP/1.1
+`
+	if got := b.String(); got != want {
+		t.Fatalf("Render() = %s, want %s", got, want)
+	}
+}
+
+func TestRenderYAMLKeywords(t *testing.T) {
+	e := testEcho()
+	e.Response.Auth = nil
+	e.Response.Headers = map[string]string{"X-Bool": "yes", "X-Off": "OFF", "X-Null": "~", "X-True": "true", "X-Word": "yesterday", "Y": "n"}
+	var b bytes.Buffer
+	if err := YAML.Render(&b, e); err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+	want := `headers:
+  X-Bool: 'yes'
+  X-Null: '~'
+  X-Off: 'OFF'
+  X-True: 'true'
+  X-Word: yesterday
+  'Y': 'n'
+`
+	if got := b.String(); !strings.HasPrefix(got, want) {
+		t.Fatalf("Render() = %s, expected to start with %s", got, want)
+	}
+}
+
+func TestRenderXML(t *testing.T) {
+	var b bytes.Buffer
+	if err := XML.Render(&b, testEcho()); err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<headerResponse>
+  <auth>
+    <authenticated>false</authenticated>
+    <prefix>/admin</prefix>
+    <realm>test</realm>
+    <reason>missing Authorization header</reason>
+    <schemes>
+      <item>basic</item>
+    </schemes>
+  </auth>
+  <headers>
+    <entry name="Accept">*/*</entry>
+    <entry name="X-Script">&lt;script&gt;</entry>
+  </headers>
+  <host>example.com</host>
+  <method>GET</method>
+  <path>/admin</path>
+  <protocol>HTTP/1.1</protocol>
+</headerResponse>
+`
+	if got := b.String(); got != want {
+		t.Fatalf("Render() = %s, want %s", got, want)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	var b bytes.Buffer
+	if err := HTML.Render(&b, testEcho()); err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"<title>HeaderTrace - GET /admin</title>",
+		`<tr class="redacted"><td>Cookie</td><td>redacted</td></tr>`,
+		`<tr class="added"><td>X-Served-By</td><td>headertrace</td></tr>`,
+		"<td>&lt;script&gt;</td>",
+		"<h2>auth</h2>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("Render() = %s, expected to contain %q", got, want)
+		}
+	}
+}
+
+func TestRenderRaw(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		raw      string
+		redacted []string
+		want     string
+		wantHex  bool
+	}{
+		{
+			name: "recorded header",
+			url:  "/?format=raw",
+			raw:  "GET /?format=raw HTTP/1.1\r\nhost: example.com\r\nx-custom: a\r\n\r\n",
+			want: "GET /?format=raw HTTP/1.1\r\nhost: example.com\r\nx-custom: a\r\n\r\n",
+		},
+		{
+			name:     "redacted header",
+			url:      "/",
+			raw:      "GET / HTTP/1.1\r\nHost: example.com\r\ncookie: a=b\r\n folded\r\nAccept: */*\r\n\r\n",
+			redacted: []string{"Cookie"},
+			want:     "GET / HTTP/1.1\r\nHost: example.com\r\ncookie: [redacted]\r\nAccept: */*\r\n\r\n",
+		},
+		{
+			name: "rebuilt header",
+			url:  "/foo",
+			want: "GET /foo HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\nX-Custom: a\r\nX-Custom: b\r\n\r\n",
+		},
+		{
+			name:    "hex dump",
+			url:     "/?hexdump=true",
+			raw:     "GET /?hexdump=true HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:    "GET /?hexdump=true HTTP/1.1\r\nHost: example.com\r\n\r\n--- hex dump (50 bytes) ---\n",
+			wantHex: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.url, nil)
+			req.RequestURI = tt.url
+			req.Header = http.Header{"Accept": {"*/*"}, "X-Custom": {"a", "b"}}
+			e := &Echo{
+				Response: api.HeaderResponse{Protocol: "HTTP/1.1"},
+				Redacted: tt.redacted,
+				Request:  req,
+			}
+			if tt.raw != "" {
+				e.Raw = []byte(tt.raw)
+			}
+
+			var b bytes.Buffer
+			if err := Raw.Render(&b, e); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			got := b.String()
+			if tt.wantHex {
+				if !strings.HasPrefix(got, tt.want) || !strings.Contains(got, "|GET /?hexdump=tr|") {
+					t.Fatalf("Render() = %q, expected a hex dump after %q", got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderSnippets(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo?a=1&format=curl", nil)
+	e := &Echo{
+		Redacted: []string{"Cookie"},
+		Request:  req,
+		Raw:      []byte("GET /foo?a=1&format=curl HTTP/1.1\r\nHost: example.com\r\nx-quote: it's\r\nCookie: a=b\r\nX-Multi: 1\r\nX-Multi: 2\r\n\r\n"),
+	}
+
+	tests := []struct {
+		format *Format
+		want   string
+	}{
+		{
+			format: Curl,
+			want: `curl 'http://example.com/foo?a=1' \
+  -H 'x-quote: it'\''s' \
+  -H 'Cookie: <REDACTED>' \
+  -H 'X-Multi: 1' \
+  -H 'X-Multi: 2'
+`,
+		},
+		{
+			format: HTTPie,
+			want: `http GET 'http://example.com/foo?a=1' \
+  'x-quote:it'\''s' \
+  'Cookie:<REDACTED>' \
+  'X-Multi:1' \
+  'X-Multi:2'
+`,
+		},
+		{
+			format: Python,
+			want: `import requests
+
+headers = {
+    "x-quote": "it's",
+    "Cookie": "<REDACTED>",
+    "X-Multi": "1, 2",
+}
+
+response = requests.request("GET", "http://example.com/foo?a=1", headers=headers)
+print(response.status_code)
+print(response.text)
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format.Name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.format.Render(&b, e); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Fatalf("Render() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("go", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := Go.Render(&b, e); err != nil {
+			t.Fatalf("Render() unexpected error = %v", err)
+		}
+		for _, want := range []string{
+			`req, err := http.NewRequest("GET", "http://example.com/foo?a=1", nil)`,
+			`req.Header["x-quote"] = append(req.Header["x-quote"], "it's")`,
+			`req.Header["Cookie"] = append(req.Header["Cookie"], "<REDACTED>")`,
+		} {
+			if !strings.Contains(b.String(), want) {
+				t.Fatalf("Render() = %s, expected to contain %q", b.String(), want)
+			}
+		}
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		tlsReq := httptest.NewRequest(http.MethodGet, "/foo?format=curl", nil)
+		tlsReq.TLS = &tls.ConnectionState{}
+		var b bytes.Buffer
+		if err := Curl.Render(&b, &Echo{Request: tlsReq}); err != nil {
+			t.Fatalf("Render() unexpected error = %v", err)
+		}
+		if want := "curl 'https://example.com/foo'"; !strings.HasPrefix(b.String(), want) {
+			t.Fatalf("Render() = %s, expected to start with %q", b.String(), want)
+		}
+	})
+
+	t.Run("body", func(t *testing.T) {
+		postReq := httptest.NewRequest(http.MethodPost, "http://example.com/hooks", nil)
+		post := &Echo{Request: postReq, Body: []byte(`{"msg":"it's"}`)}
+		for format, want := range map[*Format]string{
+			Curl:   `--data-binary '{"msg":"it'\''s"}'`,
+			HTTPie: `http --raw '{"msg":"it'\''s"}' POST`,
+			Go:     `http.NewRequest("POST", "http://example.com/hooks", strings.NewReader("{\"msg\":\"it's\"}"))`,
+			Python: `headers=headers, data="{\"msg\":\"it's\"}".encode())`,
+		} {
+			var b bytes.Buffer
+			if err := format.Render(&b, post); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			if !strings.Contains(b.String(), want) {
+				t.Fatalf("Render() = %s, expected to contain %q", b.String(), want)
+			}
+		}
+
+		for _, tt := range []struct {
+			echo *Echo
+			want string
+		}{
+			{&Echo{Request: postReq, Body: []byte{0x1f, 0x8b, 0x00}}, "# request body left out: 3 bytes of binary data\ncurl -X POST"},
+			{&Echo{Request: postReq, Body: []byte("{}"), BodyTruncated: true}, "# request body left out: only partially read by the server\ncurl -X POST"},
+		} {
+			var b bytes.Buffer
+			if err := Curl.Render(&b, tt.echo); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			if !strings.HasPrefix(b.String(), tt.want) || strings.Contains(b.String(), "--data-binary") {
+				t.Fatalf("Render() = %s, expected to start with %q and leave out the body", b.String(), tt.want)
+			}
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := Snippets.Render(&b, e); err != nil {
+			t.Fatalf("Render() unexpected error = %v", err)
+		}
+		for _, want := range []string{"# --- curl ---\n", "\n# --- httpie ---\n", "\n# --- go ---\n", "\n# --- python ---\n"} {
+			if !strings.Contains(b.String(), want) {
+				t.Fatalf("Render() = %s, expected to contain %q", b.String(), want)
+			}
+		}
+	})
+}
+
+func TestRenderHAR(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/foo?a=1&b=x%20y", nil)
+	req.Header = http.Header{"Cookie": {"session=secret"}, "Accept": {"*/*"}}
+	e := &Echo{
+		Redacted:        []string{"Cookie"},
+		ResponseHeaders: http.Header{"Content-Type": {"application/har+json"}, "Www-Authenticate": {`Basic realm="test"`}},
+		Request:         req,
+		Raw:             []byte("GET /foo?a=1&b=x%20y HTTP/1.1\r\nHost: example.com\r\nCookie: session=secret\r\nAccept: */*\r\n\r\n"),
+		Status:          http.StatusUnauthorized,
+		Received:        time.Now(),
+		HAR:             HAROptions{Version: "v1.2.3"},
+	}
+
+	var b bytes.Buffer
+	if err := HAR.Render(&b, e); err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+	var got harLog
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("Render() produced invalid JSON: %v", err)
+	}
+	if got.Log.Version != "1.2" || len(got.Log.Entries) != 1 {
+		t.Fatalf("Render() = %s, want a HAR 1.2 log with a single entry", b.String())
+	}
+	if got.Log.Creator.Version != "v1.2.3" {
+		t.Fatalf("creator version = %q, want %q", got.Log.Creator.Version, "v1.2.3")
+	}
+
+	entry := got.Log.Entries[0]
+	wantHeaders := []harPair{{"Host", "example.com"}, {"Cookie", "[redacted]"}, {"Accept", "*/*"}}
+	if !reflect.DeepEqual(entry.Request.Headers, wantHeaders) {
+		t.Fatalf("request headers = %v, want %v", entry.Request.Headers, wantHeaders)
+	}
+	if len(entry.Request.Cookies) != 0 {
+		t.Fatalf("request cookies = %v, want none as Cookie is redacted", entry.Request.Cookies)
+	}
+	wantQuery := []harPair{{"a", "1"}, {"b", "x y"}}
+	if !reflect.DeepEqual(entry.Request.QueryString, wantQuery) {
+		t.Fatalf("query string = %v, want %v", entry.Request.QueryString, wantQuery)
+	}
+	if entry.Request.URL != "http://example.com/foo?a=1&b=x%20y" || entry.Request.HeadersSize != len(e.Raw) {
+		t.Fatalf("request = %+v, unexpected URL or headers size", entry.Request)
+	}
+	if entry.Response.Status != http.StatusUnauthorized || entry.Response.StatusText != "Unauthorized" {
+		t.Fatalf("response status = %d %s, want 401 Unauthorized", entry.Response.Status, entry.Response.StatusText)
+	}
+	if entry.Response.Content.MimeType != "application/har+json" || len(entry.Response.Headers) != 2 {
+		t.Fatalf("response = %+v, unexpected content or headers", entry.Response)
+	}
+}
+
+func TestRenderJSONOptions(t *testing.T) {
+	raw := "GET / HTTP/1.1\r\nHost: example.com\r\nX-B: 2\r\nx-a: 1\r\nAccept: */*\r\n\r\n"
+	tests := []struct {
+		name   string
+		url    string
+		opts   JSONOptions
+		format *Format
+		want   string
+	}{
+		{
+			name:   "compact",
+			url:    "/?pretty=false",
+			format: JSON,
+			want:   `{"headers":{"Accept":"*/*","X-A":"1","X-B":"2"},"host":"example.com","method":"GET","path":"/","protocol":"HTTP/1.1"}` + "\n",
+		},
+		{
+			name:   "compact by default",
+			url:    "/",
+			opts:   JSONOptions{Compact: true},
+			format: JSON,
+			want:   `{"headers":{"Accept":"*/*","X-A":"1","X-B":"2"},"host":"example.com","method":"GET","path":"/","protocol":"HTTP/1.1"}` + "\n",
+		},
+		{
+			name:   "pretty overrides the default",
+			url:    "/?pretty=true&order=received",
+			opts:   JSONOptions{Compact: true},
+			format: JSON,
+			want:   "{\n  \"headers\": {\n    \"X-B\": \"2\",\n    \"X-A\": \"1\",\n    \"Accept\": \"*/*\"\n  },\n  \"host\": \"example.com\",\n  \"method\": \"GET\",\n  \"path\": \"/\",\n  \"protocol\": \"HTTP/1.1\"\n}\n",
+		},
+		{
+			name:   "received order by default",
+			url:    "/?pretty=0",
+			opts:   JSONOptions{Order: OrderReceived},
+			format: JSON,
+			want:   `{"headers":{"X-B":"2","X-A":"1","Accept":"*/*"},"host":"example.com","method":"GET","path":"/","protocol":"HTTP/1.1"}` + "\n",
+		},
+		{
+			name:   "name order overrides the default",
+			url:    "/?pretty=0&order=name",
+			opts:   JSONOptions{Order: OrderReceived},
+			format: JSON,
+			want:   `{"headers":{"Accept":"*/*","X-A":"1","X-B":"2"},"host":"example.com","method":"GET","path":"/","protocol":"HTTP/1.1"}` + "\n",
+		},
+		{
+			name:   "JSONP",
+			url:    "/?callback=widget.onEcho&pretty=false",
+			format: JSONP,
+			want:   `/**/widget.onEcho({"headers":{"Accept":"*/*","X-A":"1","X-B":"2"},"host":"example.com","method":"GET","path":"/","protocol":"HTTP/1.1"});` + "\n",
+		},
+		{
+			name:   "JSONP invalid callback",
+			url:    "/?callback=alert(1)&pretty=false",
+			format: JSON,
+			want:   `{"headers":{"Accept":"*/*","X-A":"1","X-B":"2"},"host":"example.com","method":"GET","path":"/","protocol":"HTTP/1.1"}` + "\n",
+		},
+		{
+			name:   "JSONP format without callback",
+			url:    "/?format=jsonp&pretty=false",
+			format: JSONP,
+			want:   `/**/callback({"headers":{"Accept":"*/*","X-A":"1","X-B":"2"},"host":"example.com","method":"GET","path":"/","protocol":"HTTP/1.1"});` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Echo{
+				Response: api.HeaderResponse{
+					Headers:  map[string]string{"Accept": "*/*", "X-A": "1", "X-B": "2"},
+					Host:     "example.com",
+					Method:   http.MethodGet,
+					Path:     "/",
+					Protocol: "HTTP/1.1",
+				},
+				Request: httptest.NewRequest(http.MethodGet, tt.url, nil),
+				Raw:     []byte(raw),
+				JSON:    tt.opts,
+			}
+			if got := Negotiate(e.Request, NegotiateOptions{JSONP: true}); got != tt.format {
+				t.Fatalf("Negotiate() = %s, want %s", got.Name, tt.format.Name)
+			}
+			var b bytes.Buffer
+			if err := tt.format.Render(&b, e); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Fatalf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	for _, order := range []string{OrderName, OrderReceived} {
+		if err := CheckOrder(order); err != nil {
+			t.Fatalf("CheckOrder(%q) unexpected error = %v", order, err)
+		}
+	}
+	if err := CheckOrder("random"); err == nil {
+		t.Fatalf("CheckOrder(%q) expected an error", "random")
+	}
+}
+
+func TestRenderBinary(t *testing.T) {
+	tests := []struct {
+		format *Format
+		decode func([]byte, *api.HeaderResponse) error
+	}{
+		{
+			format: CBOR,
+			decode: func(data []byte, v *api.HeaderResponse) error { return cbor.Unmarshal(data, v) },
+		},
+		{
+			format: MsgPack,
+			decode: func(data []byte, v *api.HeaderResponse) error {
+				dec := msgpack.NewDecoder(bytes.NewReader(data))
+				dec.SetCustomStructTag("json")
+				return dec.Decode(v)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format.Name, func(t *testing.T) {
+			var b bytes.Buffer
+			e := testEcho()
+			if err := tt.format.Render(&b, e); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			var got api.HeaderResponse
+			if err := tt.decode(b.Bytes(), &got); err != nil {
+				t.Fatalf("Render() produced invalid %s: %v", tt.format.Name, err)
+			}
+			if !reflect.DeepEqual(got, e.Response) {
+				t.Fatalf("Render() = %+v, want %+v", got, e.Response)
+			}
+			if bytes.Contains(b.Bytes(), []byte("cors")) {
+				t.Fatalf("Render() = %q, expected empty fields to be omitted", b.Bytes())
+			}
+		})
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want []string
+	}{
+		{
+			name: "plain",
+			url:  "/admin",
+			want: []string{
+				"GET /admin HTTP/1.1\nHost: example.com\n",
+				"\nRequest headers\n    Accept           */*\n  R Cookie           [redacted]\n  P X-Forwarded-For  10.0.0.1\n    X-Script         <script>\n",
+				"\nResponse headers\n    Content-Type  text/html\n  A X-Served-By   headertrace\n",
+				"\nauth\n  {\n    \"authenticated\": false,",
+				"\nR redacted, A added by the server, P set by a proxy\n",
+			},
+		},
+		{
+			name: "colored",
+			url:  "/admin?color=true",
+			want: []string{
+				"\x1b[1mGET /admin HTTP/1.1\x1b[0m\n",
+				"  \x1b[31mR Cookie           [redacted]\x1b[0m\n",
+				"  \x1b[32mA X-Served-By   headertrace\x1b[0m\n",
+				"  \x1b[33mP X-Forwarded-For  10.0.0.1\x1b[0m\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEcho()
+			e.Response.Headers["X-Forwarded-For"] = "10.0.0.1"
+			e.Request = httptest.NewRequest(http.MethodGet, tt.url, nil)
+			var b bytes.Buffer
+			if err := Table.Render(&b, e); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want) {
+					t.Fatalf("Render() = %q, expected to contain %q", b.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fgiudici/headertrace/pkg/capture"
+)
+
+// snippetPlaceholder replaces the values of the redacted headers in the snippets.
+const snippetPlaceholder = "<REDACTED>"
+
+// snippetSkipHeaders are not copied in the snippets: clients set them on their own.
+var snippetSkipHeaders = []string{"host", "content-length"}
+
+type headerField struct {
+	name  string
+	value string
+}
+
+// snippetRequest is the received request, as needed to reproduce it.
+type snippetRequest struct {
+	method string
+	url    string
+	fields []headerField
+	// body is the request body, empty if none or left out.
+	body string
+	// note explains why the request body is left out, if it is.
+	note string
+}
+
+func newSnippetRequest(e *Echo) snippetRequest {
+	r := e.Request
+	u := absoluteURL(r)
+	// The format parameter selects the snippets: drop it to replay the echoed request.
+	if query := r.URL.Query(); query.Has(FormatParam) {
+		query.Del(FormatParam)
+		u.RawQuery = query.Encode()
+	}
+
+	sr := snippetRequest{method: r.Method, url: u.String()}
+	for _, f := range headerFields(e) {
+		if slices.Contains(snippetSkipHeaders, strings.ToLower(f.name)) {
+			continue
+		}
+		if slices.ContainsFunc(e.Redacted, func(h string) bool { return strings.EqualFold(h, f.name) }) {
+			f.value = snippetPlaceholder
+		}
+		sr.fields = append(sr.fields, f)
+	}
+
+	// Shell arguments and string literals can't carry every byte: leave out binary bodies
+	switch {
+	case e.BodyTruncated:
+		sr.note = "request body left out: only partially read by the server"
+	case !utf8.Valid(e.Body) || bytes.IndexByte(e.Body, 0) >= 0:
+		sr.note = fmt.Sprintf("request body left out: %d bytes of binary data", len(e.Body))
+	default:
+		sr.body = string(e.Body)
+	}
+	return sr
+}
+
+// writeNote writes the note of the snippet, if any, as a comment.
+func writeNote(b *bytes.Buffer, indent, comment string, sr snippetRequest) {
+	if sr.note != "" {
+		fmt.Fprintf(b, "%s%s %s\n", indent, comment, sr.note)
+	}
+}
+
+// headerFields returns the request header fields in the order they were received, if recorded,
+// otherwise sorted by name.
+func headerFields(e *Echo) []headerField {
+	var fields []headerField
+	if e.Raw != nil {
+		for _, f := range capture.ParseHeader(e.Raw) {
+			fields = append(fields, headerField{name: f.Name, value: strings.TrimSpace(f.Value)})
+		}
+		return fields
+	}
+
+	keys := make([]string, 0, len(e.Request.Header))
+	for key := range e.Request.Header {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		for _, value := range e.Request.Header[key] {
+			fields = append(fields, headerField{name: key, value: value})
+		}
+	}
+	return fields
+}
+
+// shellQuote quotes the string for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func writeCurl(w io.Writer, sr snippetRequest) error {
+	var b bytes.Buffer
+	writeNote(&b, "", "#", sr)
+	b.WriteString("curl")
+	if sr.method != "GET" {
+		fmt.Fprintf(&b, " -X %s", sr.method)
+	}
+	fmt.Fprintf(&b, " %s", shellQuote(sr.url))
+	for _, f := range sr.fields {
+		fmt.Fprintf(&b, " \\\n  -H %s", shellQuote(f.name+": "+f.value))
+	}
+	if sr.body != "" {
+		fmt.Fprintf(&b, " \\\n  --data-binary %s", shellQuote(sr.body))
+	}
+	b.WriteString("\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeHTTPie(w io.Writer, sr snippetRequest) error {
+	var b bytes.Buffer
+	writeNote(&b, "", "#", sr)
+	b.WriteString("http")
+	if sr.body != "" {
+		fmt.Fprintf(&b, " --raw %s", shellQuote(sr.body))
+	}
+	fmt.Fprintf(&b, " %s %s", sr.method, shellQuote(sr.url))
+	for _, f := range sr.fields {
+		fmt.Fprintf(&b, " \\\n  %s", shellQuote(f.name+":"+f.value))
+	}
+	b.WriteString("\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeGo(w io.Writer, sr snippetRequest) error {
+	var b bytes.Buffer
+	b.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n")
+	body := "nil"
+	if sr.body != "" {
+		b.WriteString("\t\"strings\"\n")
+		body = fmt.Sprintf("strings.NewReader(%s)", strconv.Quote(sr.body))
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+	writeNote(&b, "\t", "//", sr)
+	fmt.Fprintf(&b, "\treq, err := http.NewRequest(%s, %s, %s)\n", strconv.Quote(sr.method), strconv.Quote(sr.url), body)
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	// Assign the header map directly to preserve the case of the header names.
+	for _, f := range sr.fields {
+		name := strconv.Quote(f.name)
+		fmt.Fprintf(&b, "\treq.Header[%s] = append(req.Header[%s], %s)\n", name, name, strconv.Quote(f.value))
+	}
+	b.WriteString(`
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Status)
+	fmt.Println(string(body))
+}
+`)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writePython(w io.Writer, sr snippetRequest) error {
+	var b bytes.Buffer
+	b.WriteString("import requests\n\nheaders = {\n")
+	// Python dicts cannot hold repeated keys: join the values of repeated headers.
+	var names []string
+	values := map[string][]string{}
+	for _, f := range sr.fields {
+		if _, ok := values[f.name]; !ok {
+			names = append(names, f.name)
+		}
+		values[f.name] = append(values[f.name], f.value)
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "    %s: %s,\n", strconv.Quote(name), strconv.Quote(strings.Join(values[name], ", ")))
+	}
+	b.WriteString("}\n\n")
+	writeNote(&b, "", "#", sr)
+	data := ""
+	if sr.body != "" {
+		// Encode the body, as requests sends str data as Latin-1
+		data = fmt.Sprintf(", data=%s.encode()", strconv.Quote(sr.body))
+	}
+	fmt.Fprintf(&b, "response = requests.request(%s, %s, headers=headers%s)\n", strconv.Quote(sr.method), strconv.Quote(sr.url), data)
+	b.WriteString("print(response.status_code)\nprint(response.text)\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// snippetWriters lists the snippet generators, by format name.
+var snippetWriters = []struct {
+	name  string
+	write func(io.Writer, snippetRequest) error
+}{
+	{"curl", writeCurl},
+	{"httpie", writeHTTPie},
+	{"go", writeGo},
+	{"python", writePython},
+}
+
+// snippetRenderer returns the render function of the named snippet, or of all the snippets
+// when name is empty.
+func snippetRenderer(name string) func(io.Writer, *Echo) error {
+	return func(w io.Writer, e *Echo) error {
+		sr := newSnippetRequest(e)
+		first := true
+		for _, sw := range snippetWriters {
+			if name != "" && sw.name != name {
+				continue
+			}
+			if name == "" {
+				if !first {
+					if _, err := io.WriteString(w, "\n"); err != nil {
+						return err
+					}
+				}
+				if _, err := fmt.Fprintf(w, "# --- %s ---\n", sw.name); err != nil {
+					return err
+				}
+			}
+			first = false
+			if err := sw.write(w, sr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
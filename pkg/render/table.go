@@ -0,0 +1,170 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fgiudici/headertrace/pkg/edge"
+	hdrs "github.com/fgiudici/headertrace/pkg/headers"
+)
+
+// ColorParam is the query parameter enabling ANSI colors in the table format.
+const ColorParam = "color"
+
+// terminalAgents are the User-Agent prefixes of the command line clients, in lower case.
+var terminalAgents = []string{"curl/", "wget/", "httpie/"}
+
+// Row markers of the table format.
+const (
+	markRedacted = "R"
+	markAdded    = "A"
+	markProxy    = "P"
+)
+
+// ANSI escape sequences of the table format.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+var markColors = map[string]string{
+	markRedacted: ansiRed,
+	markAdded:    ansiGreen,
+	markProxy:    ansiYellow,
+}
+
+// isTerminalClient reports whether the request comes from a command line client, such as
+// curl, wget or HTTPie, accepting any media type.
+func isTerminalClient(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	if !slices.ContainsFunc(terminalAgents, func(prefix string) bool { return strings.HasPrefix(ua, prefix) }) {
+		return false
+	}
+	accept := r.Header.Values("Accept")
+	return len(accept) == 1 && strings.TrimSpace(accept[0]) == "*/*"
+}
+
+type tableRow struct {
+	mark  string
+	name  string
+	value string
+}
+
+// tableWriter writes the table format, coloring the text if enabled.
+type tableWriter struct {
+	b     bytes.Buffer
+	color bool
+	marks []string
+}
+
+func (t *tableWriter) colored(color, s string) string {
+	if !t.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+func (t *tableWriter) title(s string) {
+	fmt.Fprintf(&t.b, "\n%s\n", t.colored(ansiBold, s))
+}
+
+// rows writes the rows aligning the values, and records the markers used for the legend.
+func (t *tableWriter) rows(rows []tableRow) {
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r.name))
+	}
+	for _, r := range rows {
+		mark := r.mark
+		if mark == "" {
+			mark = " "
+		} else if !slices.Contains(t.marks, mark) {
+			t.marks = append(t.marks, mark)
+		}
+		line := fmt.Sprintf("%s %-*s  %s", mark, width, r.name, r.value)
+		fmt.Fprintf(&t.b, "  %s\n", t.colored(markColors[r.mark], line))
+	}
+}
+
+// legend explains the markers used in the table.
+func (t *tableWriter) legend() {
+	if len(t.marks) == 0 {
+		return
+	}
+	descriptions := map[string]string{
+		markRedacted: "redacted",
+		markAdded:    "added by the server",
+		markProxy:    "set by a proxy",
+	}
+	var items []string
+	for _, mark := range []string{markRedacted, markAdded, markProxy} {
+		if slices.Contains(t.marks, mark) {
+			items = append(items, t.colored(markColors[mark], mark+" "+descriptions[mark]))
+		}
+	}
+	fmt.Fprintf(&t.b, "\n%s\n", strings.Join(items, ", "))
+}
+
+// renderTable writes the echo as aligned tables, for terminals. Redacted headers, headers added
+// by the server and headers set by proxies are marked, and colored when enabled with the color
+// query parameter.
+func renderTable(w io.Writer, e *Echo) error {
+	t := &tableWriter{}
+	t.color, _ = strconv.ParseBool(e.Request.URL.Query().Get(ColorParam))
+
+	fmt.Fprintf(&t.b, "%s\n", t.colored(ansiBold, fmt.Sprintf("%s %s %s", e.Response.Method, e.Response.Path, e.Response.Protocol)))
+	fmt.Fprintf(&t.b, "Host: %s\n", e.Response.Host)
+
+	var rows []tableRow
+	for name, value := range e.Response.Headers {
+		row := tableRow{name: name, value: value}
+		if hdrs.IsProxyHeader(name) || edge.IsHeader(name) {
+			row.mark = markProxy
+		}
+		rows = append(rows, row)
+	}
+	for _, name := range e.Redacted {
+		rows = append(rows, tableRow{mark: markRedacted, name: name, value: "[redacted]"})
+	}
+	byName := func(a, b tableRow) int { return strings.Compare(a.name, b.name) }
+	slices.SortFunc(rows, byName)
+	t.title("Request headers")
+	t.rows(rows)
+
+	rows = nil
+	for name, values := range e.ResponseHeaders {
+		row := tableRow{name: name, value: strings.Join(values, ",")}
+		if slices.Contains(e.Added, name) {
+			row.mark = markAdded
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) > 0 {
+		slices.SortFunc(rows, byName)
+		t.title("Response headers")
+		t.rows(rows)
+	}
+
+	sections, err := extraSections(e.Response)
+	if err != nil {
+		return err
+	}
+	for _, s := range sections {
+		t.title(s.Name)
+		for _, line := range strings.Split(s.JSON, "\n") {
+			fmt.Fprintf(&t.b, "  %s\n", line)
+		}
+	}
+
+	t.legend()
+	_, err = w.Write(t.b.Bytes())
+	return err
+}
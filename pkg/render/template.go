@@ -0,0 +1,193 @@
+package render
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/pathprefix"
+)
+
+// templateSuffixes are the file name suffixes of templates, removed to infer their media type
+// from the extension (e.g. "metrics.txt.tmpl").
+var templateSuffixes = []string{".tmpl", ".tpl", ".gotmpl"}
+
+// TemplateData is the data available to the response body templates.
+type TemplateData struct {
+	// HeaderResponse is the echo payload, as defined in the OpenAPI spec: its fields
+	// (e.g. .Headers, .Method) are accessed directly.
+	api.HeaderResponse
+	// Redacted lists the request headers redacted from the echoed headers.
+	Redacted []string
+	// Added lists the custom headers added to the response.
+	Added []string
+	// ResponseHeaders are the headers sent in the response.
+	ResponseHeaders http.Header
+	// Status is the status code of the response.
+	Status int
+	// Received is the time the request was received.
+	Received time.Time
+	// Conn describes the connection the request was received on.
+	Conn TemplateConn
+}
+
+// TemplateConn describes the connection of the request.
+type TemplateConn struct {
+	// RemoteAddr is the address of the client, as "IP:port".
+	RemoteAddr string
+	// RemoteIP is the IP address of the client.
+	RemoteIP string
+	// LocalAddr is the address the request was received on, as "IP:port".
+	LocalAddr string
+	// TLS reports whether the connection is encrypted.
+	TLS bool
+	// TLSVersion is the TLS version negotiated (e.g. "TLS 1.3"), if encrypted.
+	TLSVersion string
+	// ServerName is the server name sent by the client in the TLS handshake, if any.
+	ServerName string
+}
+
+// templateFuncs are the functions available to the response body templates, besides the
+// text/template built-in ones.
+var templateFuncs = map[string]any{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// NewTemplateFormat parses the template file and returns the format rendering it. Files with
+// an HTML extension are parsed as html/template, escaping the data, all the others as
+// text/template. The Content-Type is inferred from the file extension, text/plain by default.
+func NewTemplateFormat(path string) (*Format, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	if slices.Contains(templateSuffixes, ext) {
+		ext = filepath.Ext(strings.TrimSuffix(name, ext))
+	}
+	mediaType := "text/plain"
+	if t := mime.TypeByExtension(ext); t != "" {
+		mediaType, _, _ = strings.Cut(t, ";")
+	}
+
+	var tmpl executor
+	if mediaType == "text/html" {
+		tmpl, err = htmltemplate.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(string(content))
+	} else {
+		tmpl, err = texttemplate.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(string(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid template '%s': %w", path, err)
+	}
+
+	return &Format{
+		Name:       "template",
+		MediaTypes: []string{mediaType},
+		render: func(w io.Writer, e *Echo) error {
+			return tmpl.Execute(w, newTemplateData(e))
+		},
+	}, nil
+}
+
+func newTemplateData(e *Echo) TemplateData {
+	r := e.Request
+	data := TemplateData{
+		HeaderResponse:  e.Response,
+		Redacted:        e.Redacted,
+		Added:           e.Added,
+		ResponseHeaders: e.ResponseHeaders,
+		Status:          e.Status,
+		Received:        e.Received,
+		Conn:            TemplateConn{RemoteAddr: r.RemoteAddr, RemoteIP: r.RemoteAddr},
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		data.Conn.RemoteIP = host
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		data.Conn.LocalAddr = addr.String()
+	}
+	if r.TLS != nil {
+		data.Conn.TLS = true
+		data.Conn.TLSVersion = tls.VersionName(r.TLS.Version)
+		data.Conn.ServerName = r.TLS.ServerName
+	}
+	return data
+}
+
+type templateRoute struct {
+	prefix string
+	format *Format
+}
+
+// Templates selects the response body template of the requests by path.
+type Templates struct {
+	routes []templateRoute
+}
+
+// NewTemplates parses the template rules and the template files they point to.
+// Rules are in "[/prefix:]file" format: a rule without a prefix applies to all paths, while
+// rules with a prefix apply to the matching paths only, the longest matching prefix winning.
+func NewTemplates(rules []string) (*Templates, error) {
+	t := &Templates{}
+	for _, r := range rules {
+		prefix, path := "/", r
+		if strings.HasPrefix(r, "/") {
+			if p, file, ok := strings.Cut(r, ":"); ok {
+				prefix, path = strings.TrimSpace(p), file
+			}
+		}
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return nil, fmt.Errorf("invalid template rule '%s', expected '[/prefix:]file'", r)
+		}
+		if slices.ContainsFunc(t.routes, func(rt templateRoute) bool { return rt.prefix == prefix }) {
+			return nil, fmt.Errorf("duplicate template rule for '%s'", prefix)
+		}
+		format, err := NewTemplateFormat(path)
+		if err != nil {
+			return nil, err
+		}
+		t.routes = append(t.routes, templateRoute{prefix: prefix, format: format})
+	}
+	// Longest prefixes first, so that the most specific route wins.
+	slices.SortFunc(t.routes, func(x, y templateRoute) int { return len(y.prefix) - len(x.prefix) })
+	return t, nil
+}
+
+// Match returns the template format of the request path, or nil if no template applies.
+// Requests selecting a format with the format query parameter are not templated.
+func (t *Templates) Match(r *http.Request) *Format {
+	if r.URL.Query().Has(FormatParam) {
+		return nil
+	}
+	for _, rt := range t.routes {
+		if pathprefix.Match(r.URL.Path, rt.prefix) {
+			return rt.format
+		}
+	}
+	return nil
+}
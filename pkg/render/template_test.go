@@ -0,0 +1,144 @@
+package render
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() unexpected error = %v", err)
+	}
+	return path
+}
+
+func TestNewTemplateFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		wantType    string
+		want        string
+		wantErr     bool
+		wantMissing bool
+	}{
+		{
+			name:     "text template",
+			file:     "metrics.txt.tmpl",
+			content:  `{{range $k, $v := .Headers}}header{name="{{lower $k}}"} 1{{"\n"}}{{end}}status {{.Status}} {{.Conn.RemoteIP}}`,
+			wantType: "text/plain; charset=utf-8",
+			want:     "header{name=\"accept\"} 1\nheader{name=\"x-script\"} 1\nstatus 200 192.0.2.1",
+		},
+		{
+			name:    "invalid template",
+			file:    "dashboard.html",
+			content: `<p>{{.Headers.X-Script}}</p>`,
+			wantErr: true,
+		},
+		{
+			name:     "HTML template",
+			file:     "dashboard.html.tmpl",
+			content:  `<p>{{index .Headers "X-Script"}}</p><p>{{.Method}} {{join .Redacted ","}}</p>`,
+			wantType: "text/html; charset=utf-8",
+			want:     "<p>&lt;script&gt;</p><p>GET Cookie</p>",
+		},
+		{
+			name:     "JSON template",
+			file:     "custom.json",
+			content:  `{"host":{{json .Host}},"missing":"{{.Headers.Missing}}"}`,
+			wantType: "application/json",
+			want:     `{"host":"example.com","missing":""}`,
+		},
+		{
+			name:        "missing file",
+			file:        "",
+			wantMissing: true,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.tmpl")
+			if !tt.wantMissing {
+				path = writeTemplate(t, tt.file, tt.content)
+			}
+			f, err := NewTemplateFormat(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewTemplateFormat() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTemplateFormat() unexpected error = %v", err)
+			}
+			if got := f.ContentType(); got != tt.wantType {
+				t.Fatalf("ContentType() = %q, want %q", got, tt.wantType)
+			}
+
+			e := testEcho()
+			e.Status = http.StatusOK
+			var b bytes.Buffer
+			if err := f.Render(&b, e); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Fatalf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplatesMatch(t *testing.T) {
+	global := writeTemplate(t, "global.txt", "global")
+	api := writeTemplate(t, "api.txt", "api")
+	templates, err := NewTemplates([]string{global, "/api:" + api})
+	if err != nil {
+		t.Fatalf("NewTemplates() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "/", want: "global"},
+		{url: "/api/v1", want: "api"},
+		{url: "/api", want: "api"},
+		{url: "/apiv2", want: "global"},
+		{url: "/api/v1?format=json", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			e := testEcho()
+			e.Request = httptest.NewRequest(http.MethodGet, tt.url, nil)
+			f := templates.Match(e.Request)
+			if tt.want == "" {
+				if f != nil {
+					t.Fatalf("Match() = %v, want nil", f)
+				}
+				return
+			}
+			var b bytes.Buffer
+			if err := f.Render(&b, e); err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Fatalf("Match() rendered %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, rules := range [][]string{{"/api:"}, {global, global}, {"/api:" + global + ".missing"}} {
+		if _, err := NewTemplates(rules); err == nil {
+			t.Fatalf("NewTemplates(%v) expected an error", rules)
+		}
+	}
+}
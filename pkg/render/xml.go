@@ -0,0 +1,100 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// xmlRoot is the name of the root element of the XML format.
+const xmlRoot = "headerResponse"
+
+// renderXML writes the echo as XML. Struct fields are encoded as elements named after their
+// JSON names, slices as repeated "item" elements, and maps, whose keys (e.g. header names)
+// may not be valid XML names, as "entry" elements with a "name" attribute.
+func renderXML(w io.Writer, e *Echo) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: xmlRoot}}, reflect.ValueOf(e.Response)); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func encodeXML(enc *xml.Encoder, start xml.StartElement, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, omitEmpty := jsonName(t.Field(i))
+			if name == "" || (omitEmpty && v.Field(i).IsZero()) {
+				continue
+			}
+			if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: name}}, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case reflect.Map:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, k := range keys {
+			entry := xml.StartElement{
+				Name: xml.Name{Local: "entry"},
+				Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: k.String()}},
+			}
+			if err := encodeXML(enc, entry, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case reflect.Slice, reflect.Array:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		return enc.EncodeElement(fmt.Sprint(v.Interface()), start)
+	}
+}
+
+// jsonName returns the JSON name of the struct field and whether it is omitted when empty.
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" || !f.IsExported() {
+		return "", false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, slices.Contains(strings.Split(opts, ","), "omitempty")
+}
@@ -0,0 +1,47 @@
+package render
+
+import (
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renderYAML writes the echo as YAML. The response is converted through its JSON encoding,
+// so that field names and order match the JSON format.
+func renderYAML(w io.Writer, e *Echo) error {
+	data, err := marshalResponse(e, jsonOptions(e).Order)
+	if err != nil {
+		return err
+	}
+	// JSON is valid YAML: decoding it in a node tree preserves the fields order.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// yaml11Keywords are the plain scalars YAML 1.1 parsers read as booleans or null, in lower case.
+var yaml11Keywords = []string{"y", "n", "yes", "no", "on", "off", "true", "false", "null", "~"}
+
+// blockStyle resets the JSON flow and quoting styles of the node tree, so that the encoder
+// emits block collections and quotes only the scalars requiring it. The encoder follows YAML
+// 1.2: the strings YAML 1.1 parsers would read as booleans or null are quoted too.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" && slices.Contains(yaml11Keywords, strings.ToLower(n.Value)) {
+		n.Style = yaml.SingleQuotedStyle
+	}
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
@@ -0,0 +1,258 @@
+package smuggling
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+	"github.com/fgiudici/headertrace/pkg/capture"
+	"github.com/fgiudici/headertrace/pkg/lint"
+)
+
+// Rules of the smuggling indicators.
+const (
+	RuleCLTE         = "cl-te"
+	RuleMultipleCL   = "multiple-content-length"
+	RuleObfuscatedTE = "obfuscated-te"
+	RuleChunkSize    = "chunk-size"
+	RulePipelined    = "pipelined-request"
+)
+
+const (
+	contentLength    = "Content-Length"
+	transferEncoding = "Transfer-Encoding"
+	// mixedCase is the only obfuscation allowed by the specifications, as codings are case-insensitive
+	mixedCase = "transfer coding not in lower case"
+)
+
+// MaxBody is the maximum number of body bytes read to inspect the message framing.
+const MaxBody = 64 << 10
+
+// Inspect flags the request smuggling (desync) indicators of a request received on a
+// HTTP/1.x connection: raw is the request header block as received on the wire, pending the
+// bytes received after it (the body and any pipelined request), and bodyErr the error reading
+// the body, if any. Returns nil if raw is nil, as for HTTP/2 requests. The messages never
+// include the field values, that may be redacted.
+//
+// Only the ambiguities the Go HTTP server lets through are checked: it rejects differing
+// Content-Length values, repeated Transfer-Encoding fields, whitespace before the colon,
+// control characters and any transfer coding other than chunked.
+func Inspect(raw, pending []byte, bodyErr error) []api.LintIssue {
+	if raw == nil {
+		return nil
+	}
+
+	var issues []api.LintIssue
+	add := func(rule, severity, name, format string, args ...any) {
+		issue := api.LintIssue{Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)}
+		if name != "" {
+			issue.Field = &name
+		}
+		issues = append(issues, issue)
+	}
+
+	var cls, tes []capture.Field
+	for _, f := range capture.ParseHeader(raw) {
+		switch strings.ToLower(f.Name) {
+		case "content-length":
+			cls = append(cls, f)
+		case "transfer-encoding":
+			tes = append(tes, f)
+		}
+	}
+
+	// Content-Length framing: repeated fields reaching the handler have the same value
+	clEnd := -1
+	if len(cls) > 0 {
+		if n, err := strconv.ParseUint(strings.TrimSpace(cls[0].Value), 10, 63); err == nil {
+			clEnd = int(n)
+		}
+	}
+	if len(cls) > 1 {
+		add(RuleMultipleCL, lint.Error, contentLength, "%d Content-Length fields with the same value: merged by some hops, rejected by others", len(cls))
+	}
+
+	chunked := false
+	for _, f := range tes {
+		if reasons := obfuscation(f); len(reasons) > 0 {
+			severity := lint.Error
+			if len(reasons) == 1 && reasons[0] == mixedCase {
+				severity = lint.Warning
+			}
+			add(RuleObfuscatedTE, severity, transferEncoding, "%s", strings.Join(reasons, "; "))
+		}
+		if strings.EqualFold(strings.Trim(f.Value, " \t"), "chunked") {
+			chunked = true
+		}
+	}
+	if len(cls) > 0 && len(tes) > 0 {
+		add(RuleCLTE, lint.Error, transferEncoding, "both Content-Length and Transfer-Encoding present: the body length depends on the one each hop honours")
+	}
+	ambiguous := slices.ContainsFunc(issues, func(issue api.LintIssue) bool { return issue.Severity == lint.Error })
+
+	chunkedEnd := -1
+	if chunked {
+		end, problems := parseChunked(pending)
+		for _, p := range problems {
+			add(RuleChunkSize, p.severity, "", "%s", p.message)
+			if p.severity == lint.Error {
+				ambiguous = true
+			}
+		}
+		if bodyErr != nil && len(problems) == 0 {
+			add(RuleChunkSize, lint.Error, "", "chunked body rejected: %v", bodyErr)
+			ambiguous = true
+		}
+		chunkedEnd = end
+	}
+
+	// A request following an ambiguous body may be smuggled past the hops framing it differently
+	if ambiguous {
+		framings := []struct {
+			name string
+			end  int
+		}{{transferEncoding, chunkedEnd}, {contentLength, clEnd}}
+		seen := map[int]bool{}
+		for _, framing := range framings {
+			if framing.end < 0 || framing.end > len(pending) || seen[framing.end] {
+				continue
+			}
+			seen[framing.end] = true
+			if line, ok := requestLine(pending[framing.end:]); ok {
+				add(RulePipelined, lint.Error, "", "request '%s' follows the body as framed by %s", line, framing.name)
+			}
+		}
+	}
+	return issues
+}
+
+// obfuscation returns the reasons the Transfer-Encoding field differs from the plain form
+// all the parsers agree on, or nil.
+func obfuscation(f capture.Field) []string {
+	var reasons []string
+	if f.Folded {
+		reasons = append(reasons, "value continued on the next line (obsolete line folding)")
+	}
+	value := strings.TrimPrefix(f.Value, " ")
+	if strings.ContainsRune(value, '\t') || strings.Trim(value, " ") != value {
+		reasons = append(reasons, "value padded with tabs or extra spaces")
+	}
+	if coding := strings.Trim(value, " \t"); strings.ToLower(coding) != coding {
+		reasons = append(reasons, mixedCase)
+	}
+	return reasons
+}
+
+type problem struct {
+	severity string
+	message  string
+}
+
+// parseChunked parses the chunked body at the start of b, returning the index following its
+// end, or -1 if incomplete or invalid, and the anomalies of the chunk-size lines.
+func parseChunked(b []byte) (int, []problem) {
+	var problems []problem
+	extensions := false
+	pos := 0
+	for chunk := 1; ; chunk++ {
+		line, next, ok := readLine(b, pos)
+		if !ok {
+			return -1, problems
+		}
+		if !bytes.HasSuffix(line, []byte("\r")) {
+			problems = append(problems, problem{lint.Error, fmt.Sprintf("chunk %d: size line terminated by a bare LF", chunk)})
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		size, ext, hasExt := strings.Cut(string(line), ";")
+		if hasExt {
+			// Whitespace is allowed before the extension
+			size = strings.TrimRight(size, " \t")
+			if !extensions {
+				extensions = true
+				problems = append(problems, problem{lint.Warning, fmt.Sprintf("chunk %d: chunk extension of %d bytes", chunk, len(ext))})
+			}
+		}
+
+		n, err := parseSize(size)
+		if err != nil {
+			problems = append(problems, problem{lint.Error, fmt.Sprintf("chunk %d: %v", chunk, err)})
+			return -1, problems
+		}
+		pos = next
+		if n == 0 {
+			// Trailer section, up to the empty line
+			for {
+				line, next, ok := readLine(b, pos)
+				if !ok {
+					return -1, problems
+				}
+				pos = next
+				if len(bytes.TrimSuffix(line, []byte("\r"))) == 0 {
+					return pos, problems
+				}
+			}
+		}
+		if uint64(len(b)-pos) < n+2 {
+			return -1, problems
+		}
+		pos += int(n)
+		if !bytes.HasPrefix(b[pos:], []byte("\r\n")) {
+			problems = append(problems, problem{lint.Error, fmt.Sprintf("chunk %d: data not followed by CRLF, the chunk size does not match", chunk)})
+			return -1, problems
+		}
+		pos += 2
+	}
+}
+
+// parseSize parses a chunk size, flagging the forms some parsers accept and others reject.
+func parseSize(s string) (uint64, error) {
+	switch {
+	case s == "":
+		return 0, fmt.Errorf("empty chunk size")
+	case strings.TrimSpace(s) != s:
+		return 0, fmt.Errorf("chunk size padded with whitespace")
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		return 0, fmt.Errorf("chunk size with a 0x prefix")
+	case strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-"):
+		return 0, fmt.Errorf("chunk size with a sign")
+	}
+	n, err := strconv.ParseUint(s, 16, 64)
+	switch {
+	case errors.Is(err, strconv.ErrRange):
+		return 0, fmt.Errorf("chunk size of %d hex digits overflows 64 bits", len(s))
+	case err != nil:
+		return 0, fmt.Errorf("chunk size is not a hex number")
+	case n > 1<<62:
+		return 0, fmt.Errorf("chunk size of %d bytes is too large", n)
+	}
+	return n, nil
+}
+
+// requestLine returns the request line at the start of b, if it looks like one.
+func requestLine(b []byte) (string, bool) {
+	line, _, ok := readLine(b, 0)
+	if !ok {
+		return "", false
+	}
+	parts := strings.Fields(strings.TrimSuffix(string(line), "\r"))
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "HTTP/") {
+		return "", false
+	}
+	if strings.IndexFunc(parts[0], func(c rune) bool { return c < 'A' || c > 'Z' }) >= 0 {
+		return "", false
+	}
+	return parts[0] + " " + parts[1], true
+}
+
+// readLine returns the line of b starting at pos without the LF, and the index following it.
+func readLine(b []byte, pos int) ([]byte, int, bool) {
+	i := bytes.IndexByte(b[pos:], '\n')
+	if i < 0 {
+		return nil, 0, false
+	}
+	return b[pos : pos+i], pos + i + 1, true
+}
@@ -0,0 +1,241 @@
+package smuggling
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgiudici/headertrace/pkg/capture"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		pending string
+		bodyErr error
+		want    []string
+	}{
+		{
+			name:    "plain chunked body",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n",
+			pending: "5\r\nhello\r\n0\r\n\r\nGET /next HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		},
+		{
+			name:    "plain Content-Length body",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\n",
+			pending: "helloGET /next HTTP/1.1\r\n",
+		},
+		{
+			name:    "CL.TE with a smuggled request",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 25\r\nTransfer-Encoding: chunked\r\n\r\n",
+			pending: "0\r\n\r\nGPOST / HTTP/1.1\r\n\r\n",
+			want:    []string{"cl-te error both Content-Length and Transfer-Encoding present: the body length depends on the one each hop honours", "pipelined-request error request 'GPOST /' follows the body as framed by Transfer-Encoding"},
+		},
+		{
+			name:    "TE.CL with a smuggled request",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nContent-length: 4\r\nTransfer-Encoding: chunked\r\n\r\n",
+			pending: "5c\r\nGPOST / HTTP/1.1\r\n",
+			want:    []string{"cl-te error both Content-Length and Transfer-Encoding present: the body length depends on the one each hop honours", "pipelined-request error request 'GPOST /' follows the body as framed by Content-Length"},
+		},
+		{
+			name:    "repeated Content-Length fields",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\n",
+			pending: "hello",
+			want:    []string{"multiple-content-length error 2 Content-Length fields with the same value: merged by some hops, rejected by others"},
+		},
+		{
+			name:    "padded Transfer-Encoding",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding:\tChunked \r\n\r\n",
+			pending: "0\r\n\r\n",
+			want:    []string{"obfuscated-te error value padded with tabs or extra spaces; transfer coding not in lower case"},
+		},
+		{
+			name:    "folded Transfer-Encoding",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding:\r\n chunked\r\n\r\n",
+			pending: "0\r\n\r\n",
+			want:    []string{"obfuscated-te error value continued on the next line (obsolete line folding)"},
+		},
+		{
+			name:    "chunk-size anomalies",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n",
+			pending: "5 ;ext=1\r\nhello\r\n0x3\r\nabc\r\n0\r\n\r\n",
+			want:    []string{"chunk-size warning chunk 1: chunk extension of 5 bytes", "chunk-size error chunk 2: chunk size with a 0x prefix"},
+		},
+		{
+			name:    "mixed case transfer coding",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: Chunked\r\n\r\n",
+			pending: "0\r\n\r\nGET /next HTTP/1.1\r\n",
+			want:    []string{"obfuscated-te warning transfer coding not in lower case"},
+		},
+		{
+			name:    "chunk size mismatch",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n",
+			pending: "3\nhello\r\n0\r\n\r\n",
+			want:    []string{"chunk-size error chunk 1: size line terminated by a bare LF", "chunk-size error chunk 1: data not followed by CRLF, the chunk size does not match"},
+		},
+		{
+			name:    "chunk size overflow",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n",
+			pending: "10000000000000005\r\nhello\r\n",
+			want:    []string{"chunk-size error chunk 1: chunk size of 17 hex digits overflows 64 bits"},
+		},
+		{
+			name:    "chunked body rejected by the server",
+			raw:     "POST / HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n",
+			bodyErr: errors.New("unexpected EOF"),
+			want:    []string{"chunk-size error chunked body rejected: unexpected EOF"},
+		},
+		{
+			name: "no raw header block",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw []byte
+			if tt.raw != "" {
+				raw = []byte(tt.raw)
+			}
+
+			var got []string
+			for _, issue := range Inspect(raw, []byte(tt.pending), tt.bodyErr) {
+				got = append(got, issue.Rule+" "+issue.Severity+" "+issue.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Inspect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInspectServer sends the requests to a Go HTTP server, as headertrace does, checking that
+// the indicators are reported for the requests reaching the handler.
+func TestInspectServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _ := capture.FromRequest(r)
+			raw := conn.Header(r)
+			_, bodyErr := io.Copy(io.Discard, io.LimitReader(r.Body, MaxBody))
+			for _, issue := range Inspect(raw, conn.Pending(), bodyErr) {
+				fmt.Fprintf(w, "%s %s\n", issue.Rule, issue.Severity)
+			}
+		}),
+		ConnContext: capture.ConnContext,
+	}
+	go func() { _ = srv.Serve(capture.NewListener(l, capture.DefaultLimit)) }()
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		request    string
+		wantStatus int
+		want       []string
+	}{
+		{
+			name:       "CL.TE with a smuggled request",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 25\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\nGPOST / HTTP/1.1\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"cl-te error", "pipelined-request error"},
+		},
+		{
+			name:       "repeated Content-Length fields",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\nhello",
+			wantStatus: http.StatusOK,
+			want:       []string{"multiple-content-length error"},
+		},
+		{
+			name:       "padded Transfer-Encoding",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding:\tchunked\t\r\n\r\n0\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"obfuscated-te error"},
+		},
+		{
+			name:       "folded Transfer-Encoding",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding:\r\n chunked\r\n\r\n0\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"obfuscated-te error"},
+		},
+		{
+			name:       "mixed case transfer coding",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: Chunked\r\n\r\n0\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"obfuscated-te warning"},
+		},
+		{
+			name:       "chunk-size anomalies",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n5;a=b\r\nhello\r\n0x3\r\nabc\r\n0\r\n\r\n",
+			wantStatus: http.StatusOK,
+			want:       []string{"chunk-size warning", "chunk-size error"},
+		},
+		{
+			name:       "differing Content-Length values rejected",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\nContent-Length: 6\r\n\r\nhello",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "whitespace before the colon rejected",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding : chunked\r\n\r\n0\r\n\r\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown transfer coding rejected",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: xchunked\r\n\r\n0\r\n\r\n",
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "multiple transfer codings rejected",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked, identity\r\n\r\n0\r\n\r\n",
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "repeated Transfer-Encoding fields rejected",
+			request:    "POST / HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+			wantStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.WriteString(conn, tt.request); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("ReadResponse() error = %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if got := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n"); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("indicators = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,467 @@
+package trace
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+// Formats of the trace contexts: the lower case names of the headers they are read from.
+const (
+	// FormatW3C is the W3C Trace Context traceparent header, along with tracestate.
+	FormatW3C = "traceparent"
+	// FormatB3 is the B3 single header.
+	FormatB3 = "b3"
+	// FormatB3Multi are the B3 X-B3-* headers.
+	FormatB3Multi = "x-b3"
+	// FormatJaeger is the Jaeger uber-trace-id header.
+	FormatJaeger = "uber-trace-id"
+	// FormatCloudTrace is the Google Cloud X-Cloud-Trace-Context header.
+	FormatCloudTrace = "x-cloud-trace-context"
+	// FormatAmazon is the AWS X-Ray X-Amzn-Trace-Id header.
+	FormatAmazon = "x-amzn-trace-id"
+)
+
+// Header names of the trace contexts.
+const (
+	Traceparent        = "Traceparent"
+	Tracestate         = "Tracestate"
+	B3                 = "B3"
+	XB3TraceID         = "X-B3-TraceId"
+	XB3SpanID          = "X-B3-SpanId"
+	XB3ParentSpanID    = "X-B3-ParentSpanId"
+	XB3Sampled         = "X-B3-Sampled"
+	XB3Flags           = "X-B3-Flags"
+	UberTraceID        = "Uber-Trace-Id"
+	XCloudTraceContext = "X-Cloud-Trace-Context"
+	XAmznTraceID       = "X-Amzn-Trace-Id"
+)
+
+// MaxStateMembers is the maximum number of tracestate list members.
+const MaxStateMembers = 32
+
+var (
+	stateKey   = regexp.MustCompile(`^([a-z0-9][_0-9a-z\-*/]{0,255}|[a-z0-9][_0-9a-z\-*/]{0,240}@[a-z][_0-9a-z\-*/]{0,13})$`)
+	stateValue = regexp.MustCompile(`^[\x20-\x2b\x2d-\x3c\x3e-\x7e]{0,255}[\x21-\x2b\x2d-\x3c\x3e-\x7e]$`)
+)
+
+// Decode parses the trace context headers of the request, in the W3C Trace Context, B3 single
+// and multi header, Jaeger, Google Cloud and AWS X-Ray formats. The trace and span IDs are
+// returned as lower case hex strings, 32 and 16 digits long, so that the formats can be
+// compared: the mismatches between the valid contexts are returned, e.g. when a hop
+// regenerates one of the headers only. Returns nil if the request carries none of the headers.
+func Decode(h http.Header) *api.TraceInfo {
+	decoders := []struct {
+		format  string
+		present bool
+		decode  func(http.Header, *api.TraceContext) error
+	}{
+		{FormatW3C, len(h.Values(Traceparent)) > 0, decodeW3C},
+		{FormatB3, len(h.Values(B3)) > 0, decodeB3},
+		{FormatB3Multi, hasAny(h, XB3TraceID, XB3SpanID, XB3ParentSpanID, XB3Sampled, XB3Flags), decodeB3Multi},
+		{FormatJaeger, len(h.Values(UberTraceID)) > 0, decodeJaeger},
+		{FormatCloudTrace, len(h.Values(XCloudTraceContext)) > 0, decodeCloudTrace},
+		{FormatAmazon, len(h.Values(XAmznTraceID)) > 0, decodeAmazon},
+	}
+
+	info := &api.TraceInfo{}
+	for _, d := range decoders {
+		if !d.present {
+			continue
+		}
+		c := api.TraceContext{Format: d.format}
+		if err := d.decode(h, &c); err != nil {
+			msg := err.Error()
+			c = api.TraceContext{Format: d.format, Error: &msg}
+		} else {
+			c.Valid = true
+		}
+		info.Contexts = append(info.Contexts, c)
+	}
+	if len(info.Contexts) == 0 {
+		return nil
+	}
+
+	var mismatches []string
+	for _, field := range []struct {
+		name  string
+		value func(api.TraceContext) *string
+	}{
+		{"trace ID", func(c api.TraceContext) *string { return c.TraceId }},
+		{"span ID", func(c api.TraceContext) *string { return c.SpanId }},
+		{"sampled", func(c api.TraceContext) *string {
+			if c.Sampled == nil {
+				return nil
+			}
+			s := strconv.FormatBool(*c.Sampled)
+			return &s
+		}},
+	} {
+		var values []string
+		distinct := map[string]bool{}
+		for _, c := range info.Contexts {
+			if v := field.value(c); c.Valid && v != nil {
+				values = append(values, c.Format+" "+*v)
+				distinct[*v] = true
+			}
+		}
+		if len(distinct) > 1 {
+			mismatches = append(mismatches, fmt.Sprintf("%s mismatch: %s", field.name, strings.Join(values, ", ")))
+		}
+	}
+	if len(mismatches) > 0 {
+		info.Mismatches = &mismatches
+	}
+	return info
+}
+
+// decodeW3C parses traceparent and tracestate (W3C Trace Context). An invalid tracestate is
+// discarded, as the specification requires, leaving the context valid.
+func decodeW3C(h http.Header, c *api.TraceContext) error {
+	value, err := single(h, Traceparent)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return fmt.Errorf("expected version-traceid-parentid-flags")
+	}
+	switch version := parts[0]; {
+	case !isHex(version, 2):
+		return fmt.Errorf("invalid version '%s'", version)
+	case version == "ff":
+		return fmt.Errorf("version ff is forbidden")
+	case version == "00" && len(parts) > 4:
+		return fmt.Errorf("version 00 has 4 fields, got %d", len(parts))
+	}
+	if !isHex(parts[1], 32) || isZero(parts[1]) {
+		return fmt.Errorf("invalid trace ID '%s', expected 32 lower case hex digits, not all zero", parts[1])
+	}
+	if !isHex(parts[2], 16) || isZero(parts[2]) {
+		return fmt.Errorf("invalid parent ID '%s', expected 16 lower case hex digits, not all zero", parts[2])
+	}
+	if !isHex(parts[3], 2) {
+		return fmt.Errorf("invalid trace flags '%s', expected 2 lower case hex digits", parts[3])
+	}
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	sampled := flags&1 == 1
+	c.TraceId, c.SpanId, c.Sampled = &parts[1], &parts[2], &sampled
+
+	if values := h.Values(Tracestate); len(values) > 0 {
+		state, err := parseState(strings.Join(values, ","))
+		if err != nil {
+			msg := "tracestate discarded: " + err.Error()
+			c.Error = &msg
+		} else if len(state) > 0 {
+			c.State = &state
+		}
+	}
+	return nil
+}
+
+// parseState parses the tracestate list members.
+func parseState(value string) ([]string, error) {
+	var members []string
+	keys := map[string]bool{}
+	for _, member := range strings.Split(value, ",") {
+		member = strings.Trim(member, " \t")
+		if member == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(member, "=")
+		if !ok || !stateKey.MatchString(key) || !stateValue.MatchString(val) {
+			return nil, fmt.Errorf("invalid list member '%s'", member)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("duplicate key '%s'", key)
+		}
+		keys[key] = true
+		members = append(members, member)
+	}
+	if len(members) > MaxStateMembers {
+		return nil, fmt.Errorf("%d list members, more than %d", len(members), MaxStateMembers)
+	}
+	return members, nil
+}
+
+// decodeB3 parses the B3 single header: {traceid}-{spanid}[-{sampling}[-{parentspanid}]], or
+// a sampling decision alone.
+func decodeB3(h http.Header, c *api.TraceContext) error {
+	value, err := single(h, B3)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) == 1 {
+		return setB3Sampling(c, parts[0])
+	}
+	if len(parts) > 4 {
+		return fmt.Errorf("expected traceid-spanid-sampling-parentspanid")
+	}
+	if err := setB3IDs(c, parts[0], parts[1]); err != nil {
+		return err
+	}
+	if len(parts) > 2 {
+		if err := setB3Sampling(c, parts[2]); err != nil {
+			return err
+		}
+	}
+	if len(parts) > 3 {
+		if !isHex(parts[3], 16) {
+			return fmt.Errorf("invalid parent span ID '%s', expected 16 lower case hex digits", parts[3])
+		}
+		c.ParentSpanId = &parts[3]
+	}
+	return nil
+}
+
+// decodeB3Multi parses the X-B3-* headers.
+func decodeB3Multi(h http.Header, c *api.TraceContext) error {
+	values := map[string]string{}
+	for _, name := range []string{XB3TraceID, XB3SpanID, XB3ParentSpanID, XB3Sampled, XB3Flags} {
+		if len(h.Values(name)) == 0 {
+			continue
+		}
+		value, err := single(h, name)
+		if err != nil {
+			return err
+		}
+		values[name] = value
+	}
+
+	traceID, hasTrace := values[XB3TraceID]
+	spanID, hasSpan := values[XB3SpanID]
+	switch {
+	case hasTrace && !hasSpan:
+		return fmt.Errorf("missing %s", XB3SpanID)
+	case hasSpan && !hasTrace:
+		return fmt.Errorf("missing %s", XB3TraceID)
+	case hasTrace:
+		if err := setB3IDs(c, traceID, spanID); err != nil {
+			return err
+		}
+	}
+	if parent, ok := values[XB3ParentSpanID]; ok {
+		if !isHex(parent, 16) {
+			return fmt.Errorf("invalid %s '%s', expected 16 lower case hex digits", XB3ParentSpanID, parent)
+		}
+		c.ParentSpanId = &parent
+	}
+	if sampled, ok := values[XB3Sampled]; ok {
+		// "true" and "false" are accepted for compatibility with early implementations
+		switch sampled {
+		case "1", "true":
+			c.Sampled = ptr(true)
+		case "0", "false":
+			c.Sampled = ptr(false)
+		default:
+			return fmt.Errorf("invalid %s '%s', expected 0 or 1", XB3Sampled, sampled)
+		}
+	}
+	if flags, ok := values[XB3Flags]; ok {
+		if flags != "1" {
+			return fmt.Errorf("invalid %s '%s', expected 1", XB3Flags, flags)
+		}
+		c.Debug, c.Sampled = ptr(true), ptr(true)
+	}
+	return nil
+}
+
+// setB3IDs validates the B3 trace and span IDs, padding 64-bit trace IDs to 128 bits.
+func setB3IDs(c *api.TraceContext, traceID, spanID string) error {
+	if !isHex(traceID, 16) && !isHex(traceID, 32) || isZero(traceID) {
+		return fmt.Errorf("invalid trace ID '%s', expected 16 or 32 lower case hex digits, not all zero", traceID)
+	}
+	if !isHex(spanID, 16) || isZero(spanID) {
+		return fmt.Errorf("invalid span ID '%s', expected 16 lower case hex digits, not all zero", spanID)
+	}
+	traceID = pad(traceID, 32)
+	c.TraceId, c.SpanId = &traceID, &spanID
+	return nil
+}
+
+// setB3Sampling parses the B3 sampling state: 0, 1 or d (debug).
+func setB3Sampling(c *api.TraceContext, sampling string) error {
+	switch sampling {
+	case "0":
+		c.Sampled = ptr(false)
+	case "1":
+		c.Sampled = ptr(true)
+	case "d":
+		c.Debug, c.Sampled = ptr(true), ptr(true)
+	default:
+		return fmt.Errorf("invalid sampling state '%s', expected 0, 1 or d", sampling)
+	}
+	return nil
+}
+
+// decodeJaeger parses uber-trace-id: {trace-id}:{span-id}:{parent-span-id}:{flags}, possibly
+// URL-encoded, with the leading zeros of the IDs omitted.
+func decodeJaeger(h http.Header, c *api.TraceContext) error {
+	value, err := single(h, UberTraceID)
+	if err != nil {
+		return err
+	}
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return fmt.Errorf("expected trace-id:span-id:parent-span-id:flags")
+	}
+	traceID, spanID, parentID := strings.ToLower(parts[0]), strings.ToLower(parts[1]), strings.ToLower(parts[2])
+	if !isHexUpTo(traceID, 32) || isZero(traceID) {
+		return fmt.Errorf("invalid trace ID '%s', expected up to 32 hex digits, not all zero", parts[0])
+	}
+	if !isHexUpTo(spanID, 16) || isZero(spanID) {
+		return fmt.Errorf("invalid span ID '%s', expected up to 16 hex digits, not all zero", parts[1])
+	}
+	if !isHexUpTo(parentID, 16) {
+		return fmt.Errorf("invalid parent span ID '%s', expected up to 16 hex digits", parts[2])
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return fmt.Errorf("invalid flags '%s', expected a hex byte", parts[3])
+	}
+
+	traceID, spanID = pad(traceID, 32), pad(spanID, 16)
+	c.TraceId, c.SpanId = &traceID, &spanID
+	// A zero parent span ID, deprecated, stands for a root span
+	if !isZero(parentID) {
+		parentID = pad(parentID, 16)
+		c.ParentSpanId = &parentID
+	}
+	c.Sampled = ptr(flags&1 == 1)
+	if flags&2 == 2 {
+		c.Debug = ptr(true)
+	}
+	return nil
+}
+
+// decodeCloudTrace parses X-Cloud-Trace-Context: TRACE_ID[/SPAN_ID][;o=OPTIONS], with the span
+// ID in decimal.
+func decodeCloudTrace(h http.Header, c *api.TraceContext) error {
+	value, err := single(h, XCloudTraceContext)
+	if err != nil {
+		return err
+	}
+	ids, options, hasOptions := strings.Cut(value, ";")
+	traceID, spanID, hasSpan := strings.Cut(ids, "/")
+	traceID = strings.ToLower(traceID)
+	if !isHex(traceID, 32) || isZero(traceID) {
+		return fmt.Errorf("invalid trace ID '%s', expected 32 hex digits, not all zero", traceID)
+	}
+	c.TraceId = &traceID
+	if hasSpan {
+		n, err := strconv.ParseUint(spanID, 10, 64)
+		if err != nil || n == 0 {
+			return fmt.Errorf("invalid span ID '%s', expected a non-zero decimal 64-bit number", spanID)
+		}
+		hex := fmt.Sprintf("%016x", n)
+		c.SpanId = &hex
+	}
+	if hasOptions {
+		switch options {
+		case "o=0":
+			c.Sampled = ptr(false)
+		case "o=1":
+			c.Sampled = ptr(true)
+		default:
+			return fmt.Errorf("invalid options '%s', expected o=0 or o=1", options)
+		}
+	}
+	return nil
+}
+
+// decodeAmazon parses X-Amzn-Trace-Id: Root=1-{time}-{id}[;Parent={span}][;Sampled={0|1|?}],
+// with other fields (e.g. Self, Lineage) ignored. The trace ID joins the time and id digits.
+func decodeAmazon(h http.Header, c *api.TraceContext) error {
+	value, err := single(h, XAmznTraceID)
+	if err != nil {
+		return err
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[strings.ToLower(key)] = val
+	}
+
+	root, ok := fields["root"]
+	if !ok {
+		return fmt.Errorf("missing Root field")
+	}
+	parts := strings.Split(root, "-")
+	if len(parts) != 3 || parts[0] != "1" || !isHex(parts[1], 8) || !isHex(parts[2], 24) || isZero(parts[1]+parts[2]) {
+		return fmt.Errorf("invalid Root '%s', expected 1-{8 hex digits}-{24 hex digits}", root)
+	}
+	traceID := parts[1] + parts[2]
+	c.TraceId = &traceID
+	if parent, ok := fields["parent"]; ok {
+		if !isHex(parent, 16) || isZero(parent) {
+			return fmt.Errorf("invalid Parent '%s', expected 16 lower case hex digits, not all zero", parent)
+		}
+		c.SpanId = &parent
+	}
+	if sampled, ok := fields["sampled"]; ok {
+		// "?" defers the decision to the next hop
+		switch sampled {
+		case "0":
+			c.Sampled = ptr(false)
+		case "1":
+			c.Sampled = ptr(true)
+		case "?":
+		default:
+			return fmt.Errorf("invalid Sampled '%s', expected 0, 1 or ?", sampled)
+		}
+	}
+	return nil
+}
+
+// single returns the value of a header that must not be repeated.
+func single(h http.Header, name string) (string, error) {
+	values := h.Values(name)
+	if len(values) > 1 {
+		return "", fmt.Errorf("%s appears %d times", name, len(values))
+	}
+	return strings.TrimSpace(values[0]), nil
+}
+
+func hasAny(h http.Header, names ...string) bool {
+	for _, name := range names {
+		if len(h.Values(name)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isHex reports whether s is made of n lower case hex digits.
+func isHex(s string, n int) bool {
+	return len(s) == n && isHexUpTo(s, n)
+}
+
+// isHexUpTo reports whether s is made of 1 to n lower case hex digits.
+func isHexUpTo(s string, n int) bool {
+	if s == "" || len(s) > n {
+		return false
+	}
+	return strings.IndexFunc(s, func(c rune) bool { return (c < '0' || c > '9') && (c < 'a' || c > 'f') }) < 0
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// pad left-pads the hex string with zeros up to n digits.
+func pad(s string, n int) string {
+	return strings.Repeat("0", max(n-len(s), 0)) + s
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
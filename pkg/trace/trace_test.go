@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/fgiudici/headertrace/api"
+)
+
+func ptrString(s string) *string {
+	return &s
+}
+
+func TestDecode(t *testing.T) {
+	yes, no := ptr(true), ptr(false)
+	tests := []struct {
+		name           string
+		headers        http.Header
+		want           []api.TraceContext
+		wantMismatches []string
+	}{
+		{
+			name:    "no tracing headers",
+			headers: http.Header{"Accept": {"*/*"}},
+		},
+		{
+			name: "traceparent and tracestate",
+			headers: http.Header{
+				"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+				"Tracestate":  {"congo=t61rcWkgMzE", "rojo=00f067aa0ba902b7"},
+			},
+			want: []api.TraceContext{{
+				Format: FormatW3C, TraceId: ptrString("0af7651916cd43dd8448eb211c80319c"), SpanId: ptrString("b7ad6b7169203331"),
+				Sampled: yes, State: &[]string{"congo=t61rcWkgMzE", "rojo=00f067aa0ba902b7"}, Valid: true,
+			}},
+		},
+		{
+			name: "invalid tracestate discarded",
+			headers: http.Header{
+				"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"},
+				"Tracestate":  {"a=1,a=2"},
+			},
+			want: []api.TraceContext{{
+				Format: FormatW3C, TraceId: ptrString("0af7651916cd43dd8448eb211c80319c"), SpanId: ptrString("b7ad6b7169203331"),
+				Sampled: no, Error: ptrString("tracestate discarded: duplicate key 'a'"), Valid: true,
+			}},
+		},
+		{
+			name:    "invalid traceparent",
+			headers: http.Header{"Traceparent": {"00-00000000000000000000000000000000-b7ad6b7169203331-01"}},
+			want: []api.TraceContext{{
+				Format: FormatW3C, Error: ptrString("invalid trace ID '00000000000000000000000000000000', expected 32 lower case hex digits, not all zero"),
+			}},
+		},
+		{
+			name:    "forbidden traceparent version",
+			headers: http.Header{"Traceparent": {"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}},
+			want:    []api.TraceContext{{Format: FormatW3C, Error: ptrString("version ff is forbidden")}},
+		},
+		{
+			name:    "B3 single header",
+			headers: http.Header{"B3": {"80f198ee56343ba8-e457b5a2e4d86bd1-d-05e3ac9a4f6e3b90"}},
+			want: []api.TraceContext{{
+				Format: FormatB3, TraceId: ptrString("000000000000000080f198ee56343ba8"), SpanId: ptrString("e457b5a2e4d86bd1"),
+				ParentSpanId: ptrString("05e3ac9a4f6e3b90"), Sampled: yes, Debug: yes, Valid: true,
+			}},
+		},
+		{
+			name:    "B3 sampling only",
+			headers: http.Header{"B3": {"0"}},
+			want:    []api.TraceContext{{Format: FormatB3, Sampled: no, Valid: true}},
+		},
+		{
+			name: "B3 multiple headers",
+			headers: http.Header{
+				"X-B3-Traceid": {"463ac35c9f6413ad48485a3953bb6124"},
+				"X-B3-Spanid":  {"a2fb4a1d1a96d312"},
+				"X-B3-Sampled": {"true"},
+			},
+			want: []api.TraceContext{{
+				Format: FormatB3Multi, TraceId: ptrString("463ac35c9f6413ad48485a3953bb6124"), SpanId: ptrString("a2fb4a1d1a96d312"),
+				Sampled: yes, Valid: true,
+			}},
+		},
+		{
+			name:    "B3 trace ID without span ID",
+			headers: http.Header{"X-B3-Traceid": {"463ac35c9f6413ad48485a3953bb6124"}},
+			want: []api.TraceContext{{
+				Format: FormatB3Multi, Error: ptrString("missing X-B3-SpanId"),
+			}},
+		},
+		{
+			name:    "Jaeger URL-encoded with leading zeros omitted",
+			headers: http.Header{"Uber-Trace-Id": {"463AC35C9F6413AD%3A72485a3953bb6124%3A0%3A3"}},
+			want: []api.TraceContext{{
+				Format: FormatJaeger, TraceId: ptrString("0000000000000000463ac35c9f6413ad"), SpanId: ptrString("72485a3953bb6124"),
+				Sampled: yes, Debug: yes, Valid: true,
+			}},
+		},
+		{
+			name:    "Google Cloud with decimal span ID",
+			headers: http.Header{"X-Cloud-Trace-Context": {"105445AA7843BC8BF206B12000100000/1;o=1"}},
+			want: []api.TraceContext{{
+				Format: FormatCloudTrace, TraceId: ptrString("105445aa7843bc8bf206b12000100000"), SpanId: ptrString("0000000000000001"),
+				Sampled: yes, Valid: true,
+			}},
+		},
+		{
+			name:    "AWS X-Ray",
+			headers: http.Header{"X-Amzn-Trace-Id": {"Self=1-67891234-12456789abcdef012345678;Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=?"}},
+			want: []api.TraceContext{{
+				Format: FormatAmazon, TraceId: ptrString("5759e988bd862e3fe1be46a994272793"), SpanId: ptrString("53995c3f42cd8ad8"), Valid: true,
+			}},
+		},
+		{
+			name: "repeated header",
+			headers: http.Header{
+				"X-Amzn-Trace-Id": {"Root=1-5759e988-bd862e3fe1be46a994272793", "Root=1-5759e988-bd862e3fe1be46a994272794"},
+			},
+			want: []api.TraceContext{{Format: FormatAmazon, Error: ptrString("X-Amzn-Trace-Id appears 2 times")}},
+		},
+		{
+			name: "formats in agreement",
+			headers: http.Header{
+				"Traceparent": {"00-463ac35c9f6413ad48485a3953bb6124-a2fb4a1d1a96d312-01"},
+				"B3":          {"463ac35c9f6413ad48485a3953bb6124-a2fb4a1d1a96d312-1"},
+			},
+			want: []api.TraceContext{
+				{Format: FormatW3C, TraceId: ptrString("463ac35c9f6413ad48485a3953bb6124"), SpanId: ptrString("a2fb4a1d1a96d312"), Sampled: yes, Valid: true},
+				{Format: FormatB3, TraceId: ptrString("463ac35c9f6413ad48485a3953bb6124"), SpanId: ptrString("a2fb4a1d1a96d312"), Sampled: yes, Valid: true},
+			},
+		},
+		{
+			name: "formats in disagreement",
+			headers: http.Header{
+				"Traceparent":   {"00-463ac35c9f6413ad48485a3953bb6124-a2fb4a1d1a96d312-00"},
+				"Uber-Trace-Id": {"463ac35c9f6413ad48485a3953bb6124:b7ad6b7169203331:0:1"},
+				"B3":            {"garbage"},
+			},
+			want: []api.TraceContext{
+				{Format: FormatW3C, TraceId: ptrString("463ac35c9f6413ad48485a3953bb6124"), SpanId: ptrString("a2fb4a1d1a96d312"), Sampled: no, Valid: true},
+				{Format: FormatB3, Error: ptrString("invalid sampling state 'garbage', expected 0, 1 or d")},
+				{Format: FormatJaeger, TraceId: ptrString("463ac35c9f6413ad48485a3953bb6124"), SpanId: ptrString("b7ad6b7169203331"), Sampled: yes, Valid: true},
+			},
+			wantMismatches: []string{
+				"span ID mismatch: traceparent a2fb4a1d1a96d312, uber-trace-id b7ad6b7169203331",
+				"sampled mismatch: traceparent false, uber-trace-id true",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Decode(tt.headers)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Decode() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("Decode() = nil, want %+v", tt.want)
+			}
+			if !reflect.DeepEqual(got.Contexts, tt.want) {
+				t.Fatalf("Decode() contexts = %+v, want %+v", got.Contexts, tt.want)
+			}
+			var mismatches []string
+			if got.Mismatches != nil {
+				mismatches = *got.Mismatches
+			}
+			if !reflect.DeepEqual(mismatches, tt.wantMismatches) {
+				t.Fatalf("Decode() mismatches = %q, want %q", mismatches, tt.wantMismatches)
+			}
+		})
+	}
+}